@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/codegen/bundle"
+	"github.com/ibraimgm/bfi/codegen/native"
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+)
+
+// buildOptions are the options of build, as given in the command line
+type buildOptions struct {
+	bundle.Options
+	Output string
+	Native bool
+}
+
+// build compiles the source file into an executable. Without an output
+// file, the executable is named after the source file.
+func build(filename string, pipeline *optimizer.Pipeline, opts buildOptions) error {
+	if opts.Output == "" {
+		opts.Output = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+
+	if !opts.Native {
+		return bundle.Build(filename, opts.Output, opts.Options)
+	}
+
+	program, err := load(filename, pipeline)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(opts.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+
+	nativeOpts := native.Options{Options: codegen.Options{CellSize: opts.CellSize, TapeSize: opts.TapeSize, EOFMode: opts.EOFMode}}
+
+	if err := native.Emit(out, program, nativeOpts); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
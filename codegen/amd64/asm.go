@@ -0,0 +1,397 @@
+// Package amd64 generates x86-64 machine code for brainf*ck programs.
+//
+// The code generation does not depend on the host platform; running the
+// generated code is up to the caller.
+package amd64
+
+import (
+	"encoding/binary"
+)
+
+// Reg is a general purpose 64-bit register
+type Reg byte
+
+// List of the general purpose registers, in encoding order
+const (
+	RAX Reg = iota
+	RCX
+	RDX
+	RBX
+	RSP
+	RBP
+	RSI
+	RDI
+	R8
+	R9
+	R10
+	R11
+	R12
+	R13
+	R14
+	R15
+
+	// NoReg marks a memory operand without an index register
+	NoReg Reg = 0xFF
+)
+
+// Cond is the condition of a conditional jump
+type Cond byte
+
+// List of the conditions used by the code generator
+const (
+	CondB  Cond = 0x2 // unsigned less
+	CondAE Cond = 0x3 // unsigned greater or equal
+	CondE  Cond = 0x4 // equal (zero)
+	CondNE Cond = 0x5 // not equal (nonzero)
+)
+
+// Mem is a memory operand, addressing Base + Index*Scale + Disp
+type Mem struct {
+	Base  Reg
+	Index Reg
+	Scale byte
+	Disp  int32
+}
+
+// Label is a position in the code, that can be used as a jump target
+// before being bound
+type Label int
+
+type fixup struct {
+	at    int
+	label Label
+}
+
+// Assembler encodes instructions into a buffer. Only the instructions and
+// addressing modes needed by the code generator are supported.
+type Assembler struct {
+	buf    []byte
+	labels []int
+	fixups []fixup
+}
+
+// Len returns the size of the code generated so far
+func (a *Assembler) Len() int {
+	return len(a.buf)
+}
+
+// NewLabel returns a new, unbound label
+func (a *Assembler) NewLabel() Label {
+	a.labels = append(a.labels, -1)
+	return Label(len(a.labels) - 1)
+}
+
+// Bind sets the position of the label to the current end of the code
+func (a *Assembler) Bind(l Label) {
+	a.labels[l] = len(a.buf)
+}
+
+// Offset returns the position of a bound label
+func (a *Assembler) Offset(l Label) int {
+	return a.labels[l]
+}
+
+// Bytes resolves every jump and returns the generated code.
+// Every label used must be bound.
+func (a *Assembler) Bytes() []byte {
+	for _, f := range a.fixups {
+		rel := a.labels[f.label] - (f.at + 4)
+		binary.LittleEndian.PutUint32(a.buf[f.at:], uint32(int32(rel)))
+	}
+
+	a.fixups = a.fixups[:0]
+	return a.buf
+}
+
+func (a *Assembler) emit(b ...byte) {
+	a.buf = append(a.buf, b...)
+}
+
+func (a *Assembler) emit16(v uint16) {
+	var tmp [2]byte
+	binary.LittleEndian.PutUint16(tmp[:], v)
+	a.buf = append(a.buf, tmp[:]...)
+}
+
+func (a *Assembler) emit32(v uint32) {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], v)
+	a.buf = append(a.buf, tmp[:]...)
+}
+
+func (a *Assembler) emit64(v uint64) {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], v)
+	a.buf = append(a.buf, tmp[:]...)
+}
+
+// rex emits the REX prefix, if needed. reg, index and base are the
+// registers whose high bit goes in REX.R, REX.X and REX.B.
+func (a *Assembler) rex(w bool, reg, index, base Reg) {
+	var b byte = 0x40
+
+	if w {
+		b |= 0x08
+	}
+
+	if reg != NoReg && reg&8 != 0 {
+		b |= 0x04
+	}
+
+	if index != NoReg && index&8 != 0 {
+		b |= 0x02
+	}
+
+	if base != NoReg && base&8 != 0 {
+		b |= 0x01
+	}
+
+	if b != 0x40 {
+		a.emit(b)
+	}
+}
+
+// opRR emits an instruction with two register operands
+func (a *Assembler) opRR(w bool, op []byte, reg, rm Reg) {
+	a.rex(w, reg, NoReg, rm)
+	a.emit(op...)
+	a.emit(0xC0 | byte(reg&7)<<3 | byte(rm&7))
+}
+
+// opRM emits an instruction with a register (or opcode extension)
+// and a memory operand
+func (a *Assembler) opRM(w bool, op []byte, reg Reg, m Mem) {
+	a.rex(w, reg, m.Index, m.Base)
+	a.emit(op...)
+
+	var mod byte
+
+	switch {
+	case m.Disp == 0 && m.Base&7 != RBP:
+		mod = 0
+	case m.Disp >= -128 && m.Disp <= 127:
+		mod = 1
+	default:
+		mod = 2
+	}
+
+	r := byte(reg&7) << 3
+
+	if m.Index != NoReg || m.Base&7 == RSP {
+		index := byte(RSP)
+		if m.Index != NoReg {
+			index = byte(m.Index & 7)
+		}
+
+		a.emit(mod<<6|r|0x04, scaleBits(m.Scale)<<6|index<<3|byte(m.Base&7))
+	} else {
+		a.emit(mod<<6 | r | byte(m.Base&7))
+	}
+
+	switch mod {
+	case 1:
+		a.emit(byte(int8(m.Disp)))
+	case 2:
+		a.emit32(uint32(m.Disp))
+	}
+}
+
+func scaleBits(scale byte) byte {
+	switch scale {
+	case 2:
+		return 1
+	case 4:
+		return 2
+	case 8:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// sized emits the operand size prefix for 16-bit instructions, and returns
+// whether REX.W is needed, for the specified width in bytes
+func (a *Assembler) sized(width int) bool {
+	if width == 2 {
+		a.emit(0x66)
+	}
+
+	return width == 8
+}
+
+// MovRegMem emits mov dst, qword [m]
+func (a *Assembler) MovRegMem(dst Reg, m Mem) {
+	a.opRM(true, []byte{0x8B}, dst, m)
+}
+
+// MovMemReg emits mov qword [m], src
+func (a *Assembler) MovMemReg(m Mem, src Reg) {
+	a.opRM(true, []byte{0x89}, src, m)
+}
+
+// MovMemImm32 emits mov qword [m], imm, with the value sign-extended
+func (a *Assembler) MovMemImm32(m Mem, imm int32) {
+	a.opRM(true, []byte{0xC7}, 0, m)
+	a.emit32(uint32(imm))
+}
+
+// MovRegReg emits mov dst, src
+func (a *Assembler) MovRegReg(dst Reg, src Reg) {
+	a.opRR(true, []byte{0x89}, src, dst)
+}
+
+// MovRegImm64 emits mov dst, imm with a full 64-bit immediate
+func (a *Assembler) MovRegImm64(dst Reg, imm uint64) {
+	a.rex(true, NoReg, NoReg, dst)
+	a.emit(0xB8 + byte(dst&7))
+	a.emit64(imm)
+}
+
+// MovRegImm32 emits mov dst, imm with a 32-bit immediate, zero-extended
+func (a *Assembler) MovRegImm32(dst Reg, imm uint32) {
+	a.rex(false, NoReg, NoReg, dst)
+	a.emit(0xB8 + byte(dst&7))
+	a.emit32(imm)
+}
+
+// Lea emits lea dst, [m]
+func (a *Assembler) Lea(dst Reg, m Mem) {
+	a.opRM(true, []byte{0x8D}, dst, m)
+}
+
+// CmpRegReg emits cmp x, y
+func (a *Assembler) CmpRegReg(x Reg, y Reg) {
+	a.opRR(true, []byte{0x39}, y, x)
+}
+
+// CmpRegImm32 emits cmp r, imm, with the value sign-extended
+func (a *Assembler) CmpRegImm32(r Reg, imm int32) {
+	a.opRR(true, []byte{0x81}, 7, r)
+	a.emit32(uint32(imm))
+}
+
+// SubRegReg emits sub dst, src
+func (a *Assembler) SubRegReg(dst Reg, src Reg) {
+	a.opRR(true, []byte{0x29}, src, dst)
+}
+
+// IncReg emits inc r
+func (a *Assembler) IncReg(r Reg) {
+	a.opRR(true, []byte{0xFF}, 0, r)
+}
+
+// DecReg emits dec r
+func (a *Assembler) DecReg(r Reg) {
+	a.opRR(true, []byte{0xFF}, 1, r)
+}
+
+// ImulRegImm32 emits imul r, r, imm
+func (a *Assembler) ImulRegImm32(r Reg, imm int32) {
+	a.opRR(true, []byte{0x69}, r, r)
+	a.emit32(uint32(imm))
+}
+
+// ImulRegReg emits imul dst, src
+func (a *Assembler) ImulRegReg(dst Reg, src Reg) {
+	a.opRR(true, []byte{0x0F, 0xAF}, dst, src)
+}
+
+// LoadCell emits a load of a width-byte cell into dst, zero-extended
+func (a *Assembler) LoadCell(width int, dst Reg, m Mem) {
+	switch width {
+	case 1:
+		a.opRM(false, []byte{0x0F, 0xB6}, dst, m)
+	case 2:
+		a.opRM(false, []byte{0x0F, 0xB7}, dst, m)
+	case 4:
+		a.opRM(false, []byte{0x8B}, dst, m)
+	default:
+		a.opRM(true, []byte{0x8B}, dst, m)
+	}
+}
+
+// AddCellImm emits an addition of imm to a width-byte cell. The immediate
+// is truncated to the cell width, or sign-extended from 32 bits for 8-byte
+// cells.
+func (a *Assembler) AddCellImm(width int, m Mem, imm int32) {
+	a.cellImm(width, 0x80, 0x81, 0, m, imm)
+}
+
+// MovCellImm emits a store of imm into a width-byte cell
+func (a *Assembler) MovCellImm(width int, m Mem, imm int32) {
+	a.cellImm(width, 0xC6, 0xC7, 0, m, imm)
+}
+
+// CmpCellZero emits a comparison of a width-byte cell with zero
+func (a *Assembler) CmpCellZero(width int, m Mem) {
+	if width == 1 {
+		a.opRM(false, []byte{0x80}, 7, m)
+	} else {
+		a.opRM(a.sized(width), []byte{0x83}, 7, m)
+	}
+
+	a.emit(0)
+}
+
+func (a *Assembler) cellImm(width int, op8 byte, op byte, ext Reg, m Mem, imm int32) {
+	switch width {
+	case 1:
+		a.opRM(false, []byte{op8}, ext, m)
+		a.emit(byte(imm))
+	case 2:
+		a.opRM(a.sized(width), []byte{op}, ext, m)
+		a.emit16(uint16(imm))
+	default:
+		a.opRM(a.sized(width), []byte{op}, ext, m)
+		a.emit32(uint32(imm))
+	}
+}
+
+// AddCellReg emits an addition of the low width bytes of src to a
+// width-byte cell. For 1-byte cells, src must be one of RAX, RCX, RDX or RBX.
+func (a *Assembler) AddCellReg(width int, m Mem, src Reg) {
+	if width == 1 {
+		a.opRM(false, []byte{0x00}, src, m)
+	} else {
+		a.opRM(a.sized(width), []byte{0x01}, src, m)
+	}
+}
+
+// StoreCell emits a store of the low width bytes of src into a width-byte
+// cell. For 1-byte cells, src must be one of RAX, RCX, RDX or RBX.
+func (a *Assembler) StoreCell(width int, m Mem, src Reg) {
+	if width == 1 {
+		a.opRM(false, []byte{0x88}, src, m)
+	} else {
+		a.opRM(a.sized(width), []byte{0x89}, src, m)
+	}
+}
+
+// Jmp emits a jump to the label
+func (a *Assembler) Jmp(l Label) {
+	a.emit(0xE9)
+	a.fixups = append(a.fixups, fixup{at: len(a.buf), label: l})
+	a.emit32(0)
+}
+
+// Jcc emits a conditional jump to the label
+func (a *Assembler) Jcc(cond Cond, l Label) {
+	a.emit(0x0F, 0x80|byte(cond))
+	a.fixups = append(a.fixups, fixup{at: len(a.buf), label: l})
+	a.emit32(0)
+}
+
+// JmpMem emits an indirect jump to the address stored in [m]
+func (a *Assembler) JmpMem(m Mem) {
+	a.opRM(false, []byte{0xFF}, 4, m)
+}
+
+// Syscall emits syscall
+func (a *Assembler) Syscall() {
+	a.emit(0x0F, 0x05)
+}
+
+// Ret emits ret
+func (a *Assembler) Ret() {
+	a.emit(0xC3)
+}
@@ -0,0 +1,89 @@
+package amd64_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen/amd64"
+)
+
+func mem(base amd64.Reg, disp int32) amd64.Mem {
+	return amd64.Mem{Base: base, Index: amd64.NoReg, Disp: disp}
+}
+
+func cell(index amd64.Reg, scale byte) amd64.Mem {
+	return amd64.Mem{Base: amd64.RSI, Index: index, Scale: scale}
+}
+
+func TestEncoding(t *testing.T) {
+	testCases := []struct {
+		emit     func(a *amd64.Assembler)
+		expected []byte
+	}{
+		{emit: func(a *amd64.Assembler) { a.MovRegReg(amd64.RDI, amd64.RAX) }, expected: []byte{0x48, 0x89, 0xC7}},
+		{emit: func(a *amd64.Assembler) { a.MovRegMem(amd64.RSI, mem(amd64.RDI, 0)) }, expected: []byte{0x48, 0x8B, 0x37}},
+		{emit: func(a *amd64.Assembler) { a.MovRegMem(amd64.R8, mem(amd64.RDI, 24)) }, expected: []byte{0x4C, 0x8B, 0x47, 0x18}},
+		{emit: func(a *amd64.Assembler) { a.MovMemReg(mem(amd64.RDI, 16), amd64.RBX) }, expected: []byte{0x48, 0x89, 0x5F, 0x10}},
+		{emit: func(a *amd64.Assembler) { a.MovMemImm32(mem(amd64.RDI, 40), 7) }, expected: []byte{0x48, 0xC7, 0x47, 0x28, 0x07, 0x00, 0x00, 0x00}},
+		{emit: func(a *amd64.Assembler) { a.MovRegImm64(amd64.R9, 1) }, expected: []byte{0x49, 0xB9, 0x01, 0, 0, 0, 0, 0, 0, 0}},
+		{emit: func(a *amd64.Assembler) { a.JmpMem(mem(amd64.RDI, 32)) }, expected: []byte{0xFF, 0x67, 0x20}},
+		{emit: func(a *amd64.Assembler) { a.Lea(amd64.RAX, mem(amd64.RBX, 5)) }, expected: []byte{0x48, 0x8D, 0x43, 0x05}},
+		{emit: func(a *amd64.Assembler) { a.Lea(amd64.RAX, mem(amd64.RBX, -1000)) }, expected: []byte{0x48, 0x8D, 0x83, 0x18, 0xFC, 0xFF, 0xFF}},
+		{emit: func(a *amd64.Assembler) { a.MovRegMem(amd64.RAX, mem(amd64.RBP, 0)) }, expected: []byte{0x48, 0x8B, 0x45, 0x00}},
+		{emit: func(a *amd64.Assembler) { a.MovRegMem(amd64.RAX, mem(amd64.RSP, 8)) }, expected: []byte{0x48, 0x8B, 0x44, 0x24, 0x08}},
+		{emit: func(a *amd64.Assembler) { a.CmpRegReg(amd64.RAX, amd64.RDX) }, expected: []byte{0x48, 0x39, 0xD0}},
+		{emit: func(a *amd64.Assembler) { a.IncReg(amd64.R8) }, expected: []byte{0x49, 0xFF, 0xC0}},
+		{emit: func(a *amd64.Assembler) { a.DecReg(amd64.R10) }, expected: []byte{0x49, 0xFF, 0xCA}},
+		{emit: func(a *amd64.Assembler) { a.ImulRegImm32(amd64.RCX, 3) }, expected: []byte{0x48, 0x69, 0xC9, 0x03, 0x00, 0x00, 0x00}},
+		{emit: func(a *amd64.Assembler) { a.ImulRegReg(amd64.RCX, amd64.R9) }, expected: []byte{0x49, 0x0F, 0xAF, 0xC9}},
+		{emit: func(a *amd64.Assembler) { a.AddCellImm(1, cell(amd64.RBX, 1), 5) }, expected: []byte{0x80, 0x04, 0x1E, 0x05}},
+		{emit: func(a *amd64.Assembler) { a.AddCellImm(2, cell(amd64.RBX, 2), -1) }, expected: []byte{0x66, 0x81, 0x04, 0x5E, 0xFF, 0xFF}},
+		{emit: func(a *amd64.Assembler) { a.AddCellImm(4, cell(amd64.RAX, 4), 1) }, expected: []byte{0x81, 0x04, 0x86, 0x01, 0x00, 0x00, 0x00}},
+		{emit: func(a *amd64.Assembler) { a.AddCellImm(8, cell(amd64.RBX, 8), 1) }, expected: []byte{0x48, 0x81, 0x04, 0xDE, 0x01, 0x00, 0x00, 0x00}},
+		{emit: func(a *amd64.Assembler) { a.MovCellImm(1, cell(amd64.RBX, 1), 0) }, expected: []byte{0xC6, 0x04, 0x1E, 0x00}},
+		{emit: func(a *amd64.Assembler) { a.CmpCellZero(1, cell(amd64.RBX, 1)) }, expected: []byte{0x80, 0x3C, 0x1E, 0x00}},
+		{emit: func(a *amd64.Assembler) { a.CmpCellZero(8, cell(amd64.RBX, 8)) }, expected: []byte{0x48, 0x83, 0x3C, 0xDE, 0x00}},
+		{emit: func(a *amd64.Assembler) { a.LoadCell(1, amd64.RCX, cell(amd64.RBX, 1)) }, expected: []byte{0x0F, 0xB6, 0x0C, 0x1E}},
+		{emit: func(a *amd64.Assembler) { a.LoadCell(2, amd64.RCX, cell(amd64.RBX, 2)) }, expected: []byte{0x0F, 0xB7, 0x0C, 0x5E}},
+		{emit: func(a *amd64.Assembler) { a.AddCellReg(1, cell(amd64.RAX, 1), amd64.RCX) }, expected: []byte{0x00, 0x0C, 0x06}},
+		{emit: func(a *amd64.Assembler) { a.AddCellReg(2, cell(amd64.RAX, 2), amd64.RCX) }, expected: []byte{0x66, 0x01, 0x0C, 0x46}},
+		{emit: func(a *amd64.Assembler) { a.MovRegImm32(amd64.RAX, 60) }, expected: []byte{0xB8, 0x3C, 0x00, 0x00, 0x00}},
+		{emit: func(a *amd64.Assembler) { a.MovRegImm32(amd64.R13, 1) }, expected: []byte{0x41, 0xBD, 0x01, 0x00, 0x00, 0x00}},
+		{emit: func(a *amd64.Assembler) { a.CmpRegImm32(amd64.RAX, 1) }, expected: []byte{0x48, 0x81, 0xF8, 0x01, 0x00, 0x00, 0x00}},
+		{emit: func(a *amd64.Assembler) { a.SubRegReg(amd64.R12, amd64.R13) }, expected: []byte{0x4D, 0x29, 0xEC}},
+		{emit: func(a *amd64.Assembler) { a.StoreCell(1, cell(amd64.RBX, 1), amd64.RAX) }, expected: []byte{0x88, 0x04, 0x1E}},
+		{emit: func(a *amd64.Assembler) { a.StoreCell(8, cell(amd64.RBX, 8), amd64.RAX) }, expected: []byte{0x48, 0x89, 0x04, 0xDE}},
+		{emit: func(a *amd64.Assembler) { a.Syscall() }, expected: []byte{0x0F, 0x05}},
+		{emit: func(a *amd64.Assembler) { a.Ret() }, expected: []byte{0xC3}},
+	}
+
+	for i, test := range testCases {
+		var a amd64.Assembler
+		test.emit(&a)
+
+		if code := a.Bytes(); !bytes.Equal(code, test.expected) {
+			t.Errorf("Case %v, encoding mismatch. Expected % X, received % X", i, test.expected, code)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	var a amd64.Assembler
+	back, forward := a.NewLabel(), a.NewLabel()
+
+	a.Bind(back)
+	a.Jcc(amd64.CondE, forward)
+	a.Jmp(back)
+	a.Bind(forward)
+	a.Ret()
+
+	expected := []byte{
+		0x0F, 0x84, 0x05, 0x00, 0x00, 0x00, // je forward
+		0xE9, 0xF5, 0xFF, 0xFF, 0xFF, // jmp back
+		0xC3,
+	}
+
+	if code := a.Bytes(); !bytes.Equal(code, expected) {
+		t.Errorf("Encoding mismatch. Expected % X, received % X", expected, code)
+	}
+}
@@ -0,0 +1,199 @@
+package amd64
+
+import (
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+// State is shared between the generated code and its caller. Every field
+// has 8 bytes, and the generated code depends on their order.
+//
+// The generated code never leaves the tape or performs I/O by itself. In
+// these cases, it stores the pointer and the step count, sets IP to the
+// instruction it could not run and returns. The caller runs the instruction,
+// then resumes the code from the next one. IP is set to the length of the
+// program when it finishes.
+//
+// The code also returns every YieldInterval loop iterations, so the caller
+// can let the Go runtime preempt it. In this case, IP is set to the jump
+// instruction of the loop, and the code is resumed from it.
+type State struct {
+	Tape   uintptr // address of the first cell
+	Size   int     // number of cells in the tape
+	Pos    int     // index of the current cell
+	Steps  uint64  // number of instructions run so far
+	Resume uintptr // address of the code to resume from
+	IP     int     // instruction the code could not run
+}
+
+const (
+	stateTape = iota * 8
+	stateSize
+	statePos
+	stateSteps
+	stateResume
+	stateIP
+)
+
+// YieldInterval is the number of loop iterations run before the generated
+// code returns to its caller
+const YieldInterval = 1 << 14
+
+// Options controls the code generation
+type Options struct {
+	// CellSize is the size of the cells, in bits
+	CellSize int
+
+	// Wrapping is true when the cell arithmetic wraps around. Otherwise,
+	// every add instruction is left to the caller.
+	Wrapping bool
+}
+
+// Program is the machine code generated for a brainf*ck program.
+type Program struct {
+	// Code starts with the entry point, which expects a pointer to State in
+	// RAX, as in the Go internal register ABI. It uses every register, except
+	// RSP, R14 and R15.
+	Code []byte
+
+	// Entries holds the offset in Code of each instruction, followed by
+	// the offset of the end of the program
+	Entries []int
+}
+
+// registers used by the generated code
+const (
+	regState = RDI
+	regTape  = RSI
+	regSize  = RDX
+	regPos   = RBX
+	regSteps = R8
+	regYield = R10 // loop iterations left before returning
+)
+
+type compiler struct {
+	Assembler
+	tape   Tape
+	wrap   bool
+	labels []Label
+	exits  map[int]Label
+	order  []int
+}
+
+// Compile generates the machine code for the program. The jump and return
+// instructions must be linked, with Arg holding the index of the matching
+// instruction.
+func Compile(program []parser.Instruction, opts Options) (*Program, error) {
+	switch opts.CellSize {
+	case 8, 16, 32, 64:
+	default:
+		return nil, UnsupportedCellSizeError(opts.CellSize)
+	}
+
+	tape := Tape{Base: regTape, Pos: regPos, Size: regSize, Width: opts.CellSize / 8}
+	c := &compiler{tape: tape, wrap: opts.Wrapping, exits: make(map[int]Label)}
+	c.labels = make([]Label, len(program)+1)
+
+	for i := range c.labels {
+		c.labels[i] = c.NewLabel()
+	}
+
+	c.MovRegReg(regState, RAX)
+	c.MovRegMem(regTape, state(stateTape))
+	c.MovRegMem(regSize, state(stateSize))
+	c.MovRegMem(regPos, state(statePos))
+	c.MovRegMem(regSteps, state(stateSteps))
+	c.MovRegImm32(regYield, YieldInterval)
+	c.JmpMem(state(stateResume))
+
+	for ip, ins := range program {
+		c.Bind(c.labels[ip])
+		c.instruction(ip, ins)
+	}
+
+	c.Bind(c.labels[len(program)])
+	c.Jmp(c.exit(len(program)))
+
+	ret := c.NewLabel()
+	for _, ip := range c.order {
+		c.Bind(c.exits[ip])
+		c.MovMemImm32(state(stateIP), int32(ip))
+		c.Jmp(ret)
+	}
+
+	c.Bind(ret)
+	c.MovMemReg(state(statePos), regPos)
+	c.MovMemReg(state(stateSteps), regSteps)
+	c.Ret()
+
+	entries := make([]int, len(c.labels))
+	for i, l := range c.labels {
+		entries[i] = c.Offset(l)
+	}
+
+	return &Program{Code: c.Bytes(), Entries: entries}, nil
+}
+
+func state(field int32) Mem {
+	return Mem{Base: regState, Index: NoReg, Disp: field}
+}
+
+// exit returns the label of the code that leaves instruction ip to the caller
+func (c *compiler) exit(ip int) Label {
+	l, ok := c.exits[ip]
+
+	if !ok {
+		l = c.NewLabel()
+		c.exits[ip] = l
+		c.order = append(c.order, ip)
+	}
+
+	return l
+}
+
+// locate computes the index of the cell at delta from the current one into
+// r, leaving the instruction to the caller when it is outside of the tape, or
+// when delta is too large to be handled at all
+func (c *compiler) locate(ip int, r Reg, delta int) bool {
+	if !fitsInt32(delta) {
+		c.Jmp(c.exit(ip))
+		return false
+	}
+
+	c.Lea(r, Mem{Base: regPos, Index: NoReg, Disp: int32(delta)})
+	c.CmpRegReg(r, regSize)
+	c.Jcc(CondAE, c.exit(ip))
+	return true
+}
+
+func (c *compiler) instruction(ip int, ins parser.Instruction) {
+	switch ins.Cmd {
+	case parser.CmdAdd, parser.CmdMove, parser.CmdClear, parser.CmdMulAdd, parser.CmdScan:
+		// without wrapping, the caller checks every addition
+		if ins.Cmd == parser.CmdAdd && !c.wrap {
+			c.Jmp(c.exit(ip))
+			return
+		}
+
+		locate := func(r Reg, delta int) bool { return c.locate(ip, r, delta) }
+
+		if c.Lower(c.tape, ins, locate) {
+			c.IncReg(regSteps)
+		}
+
+	case parser.CmdJump:
+		c.IncReg(regSteps)
+		c.CmpCellZero(c.tape.Width, c.tape.Cell(regPos))
+		c.Jcc(CondE, c.labels[ins.Arg+1])
+
+	case parser.CmdReturn:
+		c.IncReg(regSteps)
+		c.CmpCellZero(c.tape.Width, c.tape.Cell(regPos))
+		c.Jcc(CondE, c.labels[ip+1])
+		c.DecReg(regYield)
+		c.Jcc(CondNE, c.labels[ins.Arg])
+		c.Jmp(c.exit(ins.Arg))
+
+	default:
+		c.Jmp(c.exit(ip))
+	}
+}
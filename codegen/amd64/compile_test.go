@@ -0,0 +1,43 @@
+package amd64_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen/amd64"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+func TestCompileEntries(t *testing.T) {
+	program, err := parser.Parse(strings.NewReader("+[->+<]."))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program[1].Arg, program[6].Arg = 6, 1
+
+	for _, size := range []int{8, 16, 32, 64} {
+		native, err := amd64.Compile(program, amd64.Options{CellSize: size, Wrapping: true})
+		if err != nil {
+			t.Fatalf("Cell size %v, unexpected error: %v", size, err)
+		}
+
+		if len(native.Entries) != len(program)+1 {
+			t.Errorf("Cell size %v, expected %v entries, received %v", size, len(program)+1, len(native.Entries))
+		}
+
+		for i := 1; i < len(native.Entries); i++ {
+			if native.Entries[i] <= native.Entries[i-1] || native.Entries[i] >= len(native.Code) {
+				t.Errorf("Cell size %v, entry %v is out of order: %v", size, i, native.Entries)
+			}
+		}
+	}
+}
+
+func TestCompileCellSize(t *testing.T) {
+	_, err := amd64.Compile(nil, amd64.Options{CellSize: 12})
+
+	if _, ok := err.(amd64.UnsupportedCellSizeError); !ok {
+		t.Errorf("Expected UnsupportedCellSizeError, received %v", err)
+	}
+}
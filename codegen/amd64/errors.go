@@ -0,0 +1,13 @@
+package amd64
+
+import (
+	"fmt"
+)
+
+// UnsupportedCellSizeError indicates that code can not be generated for
+// the specified cell size
+type UnsupportedCellSizeError int
+
+func (err UnsupportedCellSizeError) Error() string {
+	return fmt.Sprintf("unsupported cell size: %d", int(err))
+}
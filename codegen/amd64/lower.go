@@ -0,0 +1,115 @@
+package amd64
+
+import (
+	"math"
+
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+// Tape is the assignment of registers to the tape, in the generated code
+type Tape struct {
+	Base  Reg // address of the first cell
+	Pos   Reg // index of the current cell
+	Size  Reg // number of cells in the tape
+	Width int // size of the cells, in bytes
+}
+
+// Cell returns the memory operand of the cell at the index in r
+func (t Tape) Cell(r Reg) Mem {
+	return Mem{Base: t.Base, Index: r, Scale: byte(t.Width)}
+}
+
+// Locator emits the code that computes the index of the cell at delta from
+// the current one into r, handling the ends of the tape. It returns false
+// when the code leaves the instruction somewhere else instead, and never
+// reaches the code that follows.
+type Locator func(r Reg, delta int) bool
+
+// Lower emits the code of an instruction that only works on the tape: move,
+// add, clear, muladd or scan. It uses RAX, RCX and R9 as scratch registers.
+// It returns false when the code never reaches the end of the instruction,
+// because locate left it somewhere else.
+func (a *Assembler) Lower(t Tape, ins parser.Instruction, locate Locator) bool {
+	switch ins.Cmd {
+	case parser.CmdMove:
+		if !locate(RAX, ins.Arg) {
+			return false
+		}
+
+		a.MovRegReg(t.Pos, RAX)
+
+	case parser.CmdAdd:
+		r, ok := t.target(ins.Offset, locate)
+		if !ok {
+			return false
+		}
+
+		a.addDelta(t.Width, t.Cell(r), ins.Arg)
+
+	case parser.CmdClear:
+		r, ok := t.target(ins.Offset, locate)
+		if !ok {
+			return false
+		}
+
+		a.MovCellImm(t.Width, t.Cell(r), 0)
+
+	case parser.CmdMulAdd:
+		r, ok := t.target(ins.Offset, locate)
+		if !ok {
+			return false
+		}
+
+		a.LoadCell(t.Width, RCX, t.Cell(t.Pos))
+
+		if fitsInt32(ins.Arg) {
+			a.ImulRegImm32(RCX, int32(ins.Arg))
+		} else {
+			a.MovRegImm64(R9, uint64(ins.Arg))
+			a.ImulRegReg(RCX, R9)
+		}
+
+		a.AddCellReg(t.Width, t.Cell(r), RCX)
+
+	case parser.CmdScan:
+		loop, done := a.NewLabel(), a.NewLabel()
+
+		a.Bind(loop)
+		a.CmpCellZero(t.Width, t.Cell(t.Pos))
+		a.Jcc(CondE, done)
+
+		if locate(RAX, ins.Arg) {
+			a.MovRegReg(t.Pos, RAX)
+			a.Jmp(loop)
+		}
+
+		a.Bind(done)
+	}
+
+	return true
+}
+
+// target returns the register with the index of the cell at offset
+func (t Tape) target(offset int, locate Locator) (Reg, bool) {
+	if offset == 0 {
+		return t.Pos, true
+	}
+
+	return RAX, locate(RAX, offset)
+}
+
+// addDelta adds delta to the cell. Only the low bits of delta matter for
+// cells smaller than 64 bits.
+func (a *Assembler) addDelta(width int, m Mem, delta int) {
+	if width < 8 || fitsInt32(delta) {
+		a.AddCellImm(width, m, int32(delta))
+		return
+	}
+
+	a.MovRegImm64(RCX, uint64(delta))
+	a.AddCellReg(width, m, RCX)
+}
+
+func fitsInt32(value int) bool {
+	return value >= math.MinInt32 && value <= math.MaxInt32
+}
@@ -0,0 +1,52 @@
+package amd64_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen/amd64"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+func TestLower(t *testing.T) {
+	tape := amd64.Tape{Base: amd64.RSI, Pos: amd64.RBX, Size: amd64.RDX, Width: 1}
+
+	// located is the result of the locator, that emits nothing
+	testCases := []struct {
+		ins      parser.Instruction
+		located  bool
+		calls    int
+		complete bool
+		expected []byte
+	}{
+		{ins: parser.Instruction{Cmd: parser.CmdAdd, Arg: 5}, complete: true, expected: []byte{0x80, 0x04, 0x1E, 0x05}},
+		{ins: parser.Instruction{Cmd: parser.CmdClear}, complete: true, expected: []byte{0xC6, 0x04, 0x1E, 0x00}},
+		{ins: parser.Instruction{Cmd: parser.CmdAdd, Arg: 1, Offset: 2}, located: true, calls: 1, complete: true, expected: []byte{0x80, 0x04, 0x06, 0x01}},
+		{ins: parser.Instruction{Cmd: parser.CmdMove, Arg: 1}, located: true, calls: 1, complete: true, expected: []byte{0x48, 0x89, 0xC3}},
+		{ins: parser.Instruction{Cmd: parser.CmdMove, Arg: 1}, calls: 1, expected: []byte{}},
+		{ins: parser.Instruction{Cmd: parser.CmdClear, Offset: 1}, calls: 1, expected: []byte{}},
+		{ins: parser.Instruction{Cmd: parser.CmdScan, Arg: 1}, calls: 1, complete: true, expected: []byte{0x80, 0x3C, 0x1E, 0x00, 0x0F, 0x84, 0x00, 0x00, 0x00, 0x00}},
+	}
+
+	for i, test := range testCases {
+		var a amd64.Assembler
+		var calls int
+
+		complete := a.Lower(tape, test.ins, func(r amd64.Reg, delta int) bool {
+			calls++
+			return test.located
+		})
+
+		if calls != test.calls {
+			t.Errorf("Case %v, expected %v calls to locate, received %v", i, test.calls, calls)
+		}
+
+		if complete != test.complete {
+			t.Errorf("Case %v, expected complete to be %v, received %v", i, test.complete, complete)
+		}
+
+		if code := a.Bytes(); !bytes.Equal(code, test.expected) {
+			t.Errorf("Case %v, encoding mismatch. Expected % X, received % X", i, test.expected, code)
+		}
+	}
+}
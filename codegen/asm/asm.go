@@ -0,0 +1,330 @@
+// Package asm translates brainf*ck programs into x86-64 assembly for the GNU
+// assembler, in AT&T syntax.
+//
+// The generated file is a standalone linux program, with no dependency on the
+// C library, that can be built with:
+//
+//	as -o prog.o prog.s
+//	ld -o prog prog.o
+//
+// It works the same way as the executables of package native: input and
+// output are made with the read and write system calls, one byte at a time.
+// Every instruction is preceded by a comment with its source position.
+package asm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// Options controls the generated assembly
+type Options struct {
+	codegen.Options
+}
+
+// sizes holds the names that depend on the cell size
+type sizes struct {
+	suffix string // instruction suffix
+	rcx    string // RCX with the cell size
+	rax    string // RAX with the cell size
+	load   string // zero-extending load into RCX
+	loadTo string // register written by load
+}
+
+var cellSizes = map[int]sizes{
+	8:  {suffix: "b", rcx: "%cl", rax: "%al", load: "movzbl", loadTo: "%ecx"},
+	16: {suffix: "w", rcx: "%cx", rax: "%ax", load: "movzwl", loadTo: "%ecx"},
+	32: {suffix: "l", rcx: "%ecx", rax: "%eax", load: "movl", loadTo: "%ecx"},
+	64: {suffix: "q", rcx: "%rcx", rax: "%rax", load: "movq", loadTo: "%rcx"},
+}
+
+type generator struct {
+	bytes.Buffer
+	opts  Options
+	sizes sizes
+	width int
+	loops []int
+	next  int
+	line  int
+}
+
+// Emit writes the program as an assembly source file
+func Emit(w io.Writer, program []parser.Instruction, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	width := opts.CellSize / 8
+
+	// every offset in the tape must fit in a 32-bit displacement
+	if int64(opts.TapeSize)*int64(width) > math.MaxInt32 {
+		return TapeTooLargeError(opts.TapeSize)
+	}
+
+	g := &generator{opts: opts, sizes: cellSizes[opts.CellSize], width: width}
+	g.header()
+	g.start()
+
+	for _, ins := range program {
+		g.instruction(ins)
+	}
+
+	g.exit()
+	g.subroutines(program)
+
+	_, err := w.Write(g.Bytes())
+	return err
+}
+
+func (g *generator) header() {
+	g.printf("# %s\n", codegen.Header(g.opts.Source))
+	g.printf("#\n")
+	g.printf("# Registers:\n")
+	g.printf("#   %%rbx  address of the first cell\n")
+	g.printf("#   %%r12  index of the current cell\n")
+	g.printf("#   %%r13  number of cells in the tape\n\n")
+
+	g.printf("\t.set\tTAPE_SIZE, %d\n", g.opts.TapeSize)
+	g.printf("\t.set\tCELL_BYTES, %d\n\n", g.width)
+
+	g.printf("\t.bss\n")
+	g.printf("\t.lcomm\ttape, TAPE_SIZE * CELL_BYTES\n")
+	g.printf("\t.lcomm\tscratch, 1\n\n")
+
+	g.printf("\t.section .rodata\n")
+	g.printf("eof_message:\n")
+	g.printf("\t.ascii\t\"ran out of input\\n\"\n")
+	g.printf("\t.set\tEOF_MESSAGE_SIZE, . - eof_message\n\n")
+}
+
+func (g *generator) start() {
+	g.printf("\t.text\n")
+	g.printf("\t.globl\t_start\n")
+	g.printf("_start:\n")
+	g.op("leaq", "tape(%rip), %rbx")
+	g.op("xorl", "%r12d, %r12d")
+	g.op("movq", "$TAPE_SIZE, %r13")
+}
+
+func (g *generator) exit() {
+	g.printf("\n# exit(0)\n")
+	g.op("movl", "$231, %eax")
+	g.op("xorl", "%edi, %edi")
+	g.op("syscall", "")
+}
+
+// comment writes the source position of the instruction, starting a new
+// block whenever the source line changes
+func (g *generator) comment(ins parser.Instruction) {
+	if line := ins.Span.Start.Line; line != g.line {
+		g.line = line
+		g.printf("\n# line %d\n", line)
+	}
+
+	g.printf("\t# %v: %v\n", ins.Span, ins)
+}
+
+func (g *generator) instruction(ins parser.Instruction) {
+	g.comment(ins)
+
+	switch ins.Cmd {
+	case parser.CmdMove:
+		g.move(ins.Arg)
+
+	case parser.CmdAdd:
+		g.add(g.cell(g.target(ins.Offset)), ins.Arg)
+
+	case parser.CmdClear:
+		g.op("mov"+g.sizes.suffix, "$0, "+g.cell(g.target(ins.Offset)))
+
+	case parser.CmdMulAdd:
+		g.op(g.sizes.load, g.cell("%r12")+", "+g.sizes.loadTo)
+
+		if ins.Arg >= math.MinInt32 && ins.Arg <= math.MaxInt32 {
+			g.op("imulq", fmt.Sprintf("$%d, %%rcx", ins.Arg))
+		} else {
+			g.op("movabsq", fmt.Sprintf("$%d, %%r9", ins.Arg))
+			g.op("imulq", "%r9, %rcx")
+		}
+
+		g.op("add"+g.sizes.suffix, g.sizes.rcx+", "+g.cell(g.target(ins.Offset)))
+
+	case parser.CmdScan:
+		n := g.label()
+
+		g.printf(".Lscan%d:\n", n)
+		g.op("cmp"+g.sizes.suffix, "$0, "+g.cell("%r12"))
+		g.op("je", fmt.Sprintf(".Lscan%d_end", n))
+		g.move(ins.Arg)
+		g.op("jmp", fmt.Sprintf(".Lscan%d", n))
+		g.printf(".Lscan%d_end:\n", n)
+
+	case parser.CmdJump:
+		n := g.label()
+		g.loops = append(g.loops, n)
+
+		g.op("cmp"+g.sizes.suffix, "$0, "+g.cell("%r12"))
+		g.op("je", fmt.Sprintf(".Lloop%d_end", n))
+		g.printf(".Lloop%d:\n", n)
+
+	case parser.CmdReturn:
+		n := g.loops[len(g.loops)-1]
+		g.loops = g.loops[:len(g.loops)-1]
+
+		g.op("cmp"+g.sizes.suffix, "$0, "+g.cell("%r12"))
+		g.op("jne", fmt.Sprintf(".Lloop%d", n))
+		g.printf(".Lloop%d_end:\n", n)
+
+	case parser.CmdInput:
+		g.op("call", "read_cell")
+
+	case parser.CmdOutput:
+		g.op("call", "write_cell")
+	}
+}
+
+func (g *generator) label() int {
+	g.next++
+	return g.next
+}
+
+// cell returns the memory operand of the cell at the index in r
+func (g *generator) cell(r string) string {
+	return fmt.Sprintf("(%%rbx,%s,%d)", r, g.width)
+}
+
+// locate computes the index of the cell at delta from the current one into
+// r, wrapping around the end of the tape
+func (g *generator) locate(r string, delta int) {
+	g.op("leaq", fmt.Sprintf("%d(%%r12), %s", codegen.Wrap(delta, g.opts.TapeSize), r))
+	g.op("cmpq", "%r13, "+r)
+	g.op("jb", "1f")
+	g.op("subq", "%r13, "+r)
+	g.printf("1:\n")
+}
+
+// target returns the register with the index of the cell at offset
+func (g *generator) target(offset int) string {
+	if codegen.Wrap(offset, g.opts.TapeSize) == 0 {
+		return "%r12"
+	}
+
+	g.locate("%rax", offset)
+	return "%rax"
+}
+
+func (g *generator) move(delta int) {
+	if codegen.Wrap(delta, g.opts.TapeSize) != 0 {
+		g.locate("%r12", delta)
+	}
+}
+
+// add adds delta to the cell, subtracting instead when it gives a smaller
+// constant
+func (g *generator) add(cell string, delta int) {
+	value := codegen.Truncate(delta, g.opts.CellSize)
+	op, step := "add", "inc"
+
+	if negated := codegen.Truncate(-delta, g.opts.CellSize); negated < value {
+		value, op, step = negated, "sub", "dec"
+	}
+
+	switch {
+	case value == 0:
+		return
+	case value == 1:
+		g.op(step+g.sizes.suffix, cell)
+	case g.width < 8 || value <= math.MaxInt32:
+		g.op(op+g.sizes.suffix, fmt.Sprintf("$%d, %s", value, cell))
+	default:
+		g.op("movabsq", fmt.Sprintf("$%d, %%rcx", value))
+		g.op(op+"q", "%rcx, "+cell)
+	}
+}
+
+// subroutines writes the functions called by the program
+func (g *generator) subroutines(program []parser.Instruction) {
+	var output bool
+	for _, ins := range program {
+		output = output || ins.Cmd == parser.CmdOutput
+	}
+
+	if output {
+		g.printf("\n# write_cell writes the low byte of the current cell\n")
+		g.printf("write_cell:\n")
+		g.op("leaq", g.cell("%r12")+", %rsi")
+		g.syscall("write", "stdout", "1")
+		g.op("ret", "")
+	}
+
+	if !codegen.HasInput(program) {
+		return
+	}
+
+	g.printf("\n# read_cell reads a byte into the current cell\n")
+	g.printf("read_cell:\n")
+	g.op("leaq", "scratch(%rip), %rsi")
+	g.syscall("read", "stdin", "1")
+	g.op("cmpq", "$1, %rax")
+	g.op("jne", "1f")
+	g.op("movzbl", "scratch(%rip), %eax")
+	g.op("mov"+g.sizes.suffix, g.sizes.rax+", "+g.cell("%r12"))
+	g.op("ret", "")
+	g.printf("1:\n")
+
+	switch g.opts.EOFMode {
+	case vm.EOFUnchanged:
+		g.printf("\t# the cell is left unchanged\n")
+	case vm.EOFZero:
+		g.op("mov"+g.sizes.suffix, "$0, "+g.cell("%r12"))
+	case vm.EOFMinusOne:
+		g.op("mov"+g.sizes.suffix, "$-1, "+g.cell("%r12"))
+	default:
+		g.op("leaq", "eof_message(%rip), %rsi")
+		g.syscall("write", "stderr", "$EOF_MESSAGE_SIZE")
+		g.op("movl", "$231, %eax")
+		g.op("movl", "$1, %edi")
+		g.op("syscall", "")
+		return
+	}
+
+	g.op("ret", "")
+}
+
+var (
+	syscalls = map[string]int{"read": 0, "write": 1}
+	files    = map[string]int{"stdin": 0, "stdout": 1, "stderr": 2}
+)
+
+// syscall makes a system call with the buffer already in RSI. The count is
+// either a number or an immediate operand.
+func (g *generator) syscall(name string, file string, count string) {
+	if !strings.HasPrefix(count, "$") {
+		count = "$" + count
+	}
+
+	g.op("movl", fmt.Sprintf("$%d, %%eax\t# %s", syscalls[name], name))
+	g.op("movl", fmt.Sprintf("$%d, %%edi\t# %s", files[file], file))
+	g.op("movl", count+", %edx")
+	g.op("syscall", "")
+}
+
+// op writes a single instruction
+func (g *generator) op(name string, operands string) {
+	if operands == "" {
+		g.printf("\t%s\n", name)
+	} else {
+		g.printf("\t%s\t%s\n", name, operands)
+	}
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g, format, args...)
+}
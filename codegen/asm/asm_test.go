@@ -0,0 +1,107 @@
+package asm_test
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/codegen/asm"
+	"github.com/ibraimgm/bfi/codegen/codegentest"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+func TestEmitComments(t *testing.T) {
+	program, err := codegentest.Program("read one\n,\nwrite it\n.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := asm.Options{Options: codegen.DefaultOptions()}
+	opts.Source = "io.bf"
+
+	var buf bytes.Buffer
+	if err := asm.Emit(&buf, program, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"# Code generated by bfi from io.bf. DO NOT EDIT.",
+		"# line 2\n\t# 2:1-2:2: input\n\tcall\tread_cell",
+		"# line 4\n\t# 4:1-4:2: output\n\tcall\twrite_cell",
+	}
+
+	for i, s := range expected {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Case %v, expected \"%v\" in the generated code:\n%s", i, s, buf.String())
+		}
+	}
+}
+
+func TestEmitCellSizes(t *testing.T) {
+	testCases := []struct {
+		cellSize int
+		expected []string
+	}{
+		{cellSize: 8, expected: []string{"addb\t$2, (%rbx,%r12,1)", "decb\t(%rbx,%r12,1)", "movb\t$-1, (%rbx,%r12,1)"}},
+		{cellSize: 16, expected: []string{"addw\t$2, (%rbx,%r12,2)", "decw\t(%rbx,%r12,2)", "movw\t$-1, (%rbx,%r12,2)"}},
+		{cellSize: 32, expected: []string{"addl\t$2, (%rbx,%r12,4)", "decl\t(%rbx,%r12,4)", "movl\t$-1, (%rbx,%r12,4)"}},
+		{cellSize: 64, expected: []string{"addq\t$2, (%rbx,%r12,8)", "decq\t(%rbx,%r12,8)", "movq\t$-1, (%rbx,%r12,8)"}},
+	}
+
+	program, err := codegentest.Program("++.-.,")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, test := range testCases {
+		opts := asm.Options{Options: codegen.Options{CellSize: test.cellSize, TapeSize: 10, EOFMode: vm.EOFMinusOne}}
+
+		var buf bytes.Buffer
+		if err := asm.Emit(&buf, program, opts); err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		for _, s := range test.expected {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("Case %v, expected \"%v\" in the generated code:\n%s", i, s, buf.String())
+			}
+		}
+	}
+}
+
+// TestEmitMatchesVM builds the generated programs with the GNU assembler
+// and linker, and compares them with the virtual machine
+func TestEmitMatchesVM(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("the programs only run on linux/amd64")
+	}
+
+	as, err := exec.LookPath("as")
+	if err != nil {
+		t.Skip("no assembler available")
+	}
+
+	ld, err := exec.LookPath("ld")
+	if err != nil {
+		t.Skip("no linker available")
+	}
+
+	emit := func(w io.Writer, program []parser.Instruction, opts codegen.Options) error {
+		return asm.Emit(w, program, asm.Options{Options: opts})
+	}
+
+	codegentest.Run(t, ".s", emit, func(_ codegentest.Case, src string) (*exec.Cmd, error) {
+		bin := strings.TrimSuffix(src, ".s")
+
+		if err := codegentest.Exec(exec.Command(as, "-o", bin+".o", src)); err != nil {
+			return nil, err
+		}
+
+		return exec.Command(bin), codegentest.Exec(exec.Command(ld, "-o", bin, bin+".o"))
+	})
+}
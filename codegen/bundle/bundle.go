@@ -0,0 +1,289 @@
+// Package bundle builds self-contained executables from brainf*ck programs,
+// that embed the source code and run it with the virtual machine.
+//
+// The executable is built by the local Go toolchain, from a temporary module
+// that depends on bfi. Every virtual machine setting is fixed when the
+// executable is built.
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"strings"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// ModulePath is the path of the bfi module, required by the generated module
+const ModulePath = "github.com/ibraimgm/bfi"
+
+// sourceFile is the name of the embedded copy of the source code
+const sourceFile = "program.bf"
+
+// Options are the settings of the virtual machine in the executable
+type Options struct {
+	CellSize     int
+	TapeSize     int
+	EOFMode      vm.EOFMode
+	TapeMode     vm.TapeMode
+	OverflowMode vm.OverflowMode
+	Signed       bool
+	InputMode    vm.InputMode
+	OutputMode   vm.OutputMode
+	Delimiter    string
+	Backend      vm.Backend
+
+	// Level and Disabled are the optimization level and the names of the
+	// optimizer passes turned off
+	Level    int
+	Disabled []string
+
+	// Module is the version of bfi required by the generated module, or the
+	// path of a local copy of it, when it starts with "." or is absolute
+	Module string
+}
+
+// DefaultOptions returns the same settings used by a new virtual machine, and
+// the version of bfi of the running executable, if known
+func DefaultOptions() Options {
+	return Options{
+		CellSize:  8,
+		TapeSize:  3000,
+		Delimiter: " ",
+		Level:     optimizer.MaxLevel,
+		Module:    moduleVersion(),
+	}
+}
+
+// moduleVersion returns the version of bfi in the running executable. It is
+// empty unless bfi was built from a tagged release.
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	version := ""
+	if info.Main.Path == ModulePath {
+		version = info.Main.Version
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == ModulePath {
+			version = dep.Version
+		}
+	}
+
+	return releaseVersion(version)
+}
+
+var (
+	semverPattern = regexp.MustCompile(`^v[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?$`)
+	pseudoPattern = regexp.MustCompile(`[0-9]{14}-[0-9a-f]{12}$`)
+)
+
+// releaseVersion returns the version if it names a tagged release. Local
+// builds may have no version ("(devel)"), or a pseudo-version of the current
+// commit, possibly with uncommitted changes ("+dirty"), that may never have
+// been published.
+func releaseVersion(version string) string {
+	if !semverPattern.MatchString(version) || pseudoPattern.MatchString(version) {
+		return ""
+	}
+
+	return version
+}
+
+// Validate checks if the options can be used to build an executable
+func (opts Options) Validate() error {
+	if err := (codegen.Options{CellSize: opts.CellSize, TapeSize: opts.TapeSize}).Validate(); err != nil {
+		return err
+	}
+
+	modes := []struct {
+		kind  string
+		value fmt.Stringer
+	}{
+		{"EOF", opts.EOFMode},
+		{"tape", opts.TapeMode},
+		{"overflow", opts.OverflowMode},
+		{"input", opts.InputMode},
+		{"output", opts.OutputMode},
+		{"backend", opts.Backend},
+	}
+
+	for _, mode := range modes {
+		if mode.value.String() == "unknown" {
+			return vm.InvalidModeError{Kind: mode.kind, Name: fmt.Sprintf("%d", mode.value)}
+		}
+	}
+
+	pipeline, err := optimizer.New(opts.Level)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range opts.Disabled {
+		if err := pipeline.Disable(name); err != nil {
+			return err
+		}
+	}
+
+	if opts.Module == "" {
+		return ErrUnknownModule
+	}
+
+	return nil
+}
+
+// isLocal returns true if the module is a directory, instead of a version
+func (opts Options) isLocal() bool {
+	return filepath.IsAbs(opts.Module) || strings.HasPrefix(opts.Module, ".")
+}
+
+// Generate writes the module of the executable into dir: its go.mod, the
+// main package and a copy of the source code. Name is the name of the
+// source file, used in the error messages.
+func Generate(dir string, name string, source []byte, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	var mod bytes.Buffer
+
+	fmt.Fprintf(&mod, "module bfprogram\n\ngo 1.18\n\n")
+
+	if opts.isLocal() {
+		path, err := filepath.Abs(opts.Module)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&mod, "require %s v0.0.0\n\nreplace %s => %s\n", ModulePath, ModulePath, path)
+	} else {
+		fmt.Fprintf(&mod, "require %s %s\n", ModulePath, opts.Module)
+	}
+
+	main, err := mainSource(name, opts)
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		"go.mod":   mod.Bytes(),
+		"main.go":  main,
+		sourceFile: source,
+	}
+
+	for file, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), contents, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Build generates the module of the executable in a temporary directory, and
+// builds it into output with the go command
+func Build(filename string, output string, opts Options) error {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	output, err = filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "bfi-build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := Generate(dir, filepath.Base(filename), source, opts); err != nil {
+		return err
+	}
+
+	// a published version needs its checksum in go.sum
+	cmd := exec.Command("go", "build", "-mod=mod", "-o", output, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off")
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return &BuildError{Err: err, Output: string(out)}
+	}
+
+	return nil
+}
+
+// identifiers of the settings in the generated code
+var (
+	eofModes      = []string{"EOFError", "EOFUnchanged", "EOFZero", "EOFMinusOne"}
+	tapeModes     = []string{"TapeWrap", "TapeError", "TapeGrow", "TapeInfinite"}
+	overflowModes = []string{"OverflowWrap", "OverflowSaturate", "OverflowError"}
+	inputModes    = []string{"InputByte", "InputUTF8", "InputDecimal"}
+	outputModes   = []string{"OutputUTF8", "OutputByte", "OutputUTF16LE", "OutputDecimal"}
+	backends      = []string{"BackendBytecode", "BackendClosure", "BackendJIT"}
+)
+
+// mainSource returns the gofmt'd main package of the executable
+func mainSource(name string, opts Options) ([]byte, error) {
+	var b bytes.Buffer
+	printf := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format, args...)
+	}
+
+	printf("// %s\n\n", codegen.Header(name))
+	printf("package main\n\n")
+	printf("import (\n\"bytes\"\n_ \"embed\"\n\"errors\"\n\"fmt\"\n\"io\"\n\"os\"\n\n")
+	printf("\"%s/interpreter/optimizer\"\n\"%s/vm\"\n)\n\n", ModulePath, ModulePath)
+
+	printf("//go:embed %s\nvar source []byte\n\n", sourceFile)
+
+	printf("const sourceName = %q\n\n", name)
+
+	printf("func main() {\n")
+	printf("err := run()\nvar runtimeErr *vm.RuntimeError\n\n")
+	printf("switch {\ncase err == nil:\nreturn\n")
+	printf("case errors.As(err, &runtimeErr) && errors.Is(err, io.EOF):\n")
+	printf("fmt.Fprintf(os.Stderr, \"%%s:%%v: ran out of input\\n\", sourceName, runtimeErr.Span.Start)\n")
+	printf("case runtimeErr != nil:\n")
+	printf("fmt.Fprintf(os.Stderr, \"%%s:%%v\\n\", sourceName, err)\n")
+	printf("default:\n")
+	printf("fmt.Fprintf(os.Stderr, \"%%s: %%v\\n\", sourceName, err)\n")
+	printf("}\n\nos.Exit(1)\n}\n\n")
+
+	printf("func run() error {\n")
+	printf("pipeline, err := optimizer.New(%d)\nif err != nil {\nreturn err\n}\n\n", opts.Level)
+
+	for _, pass := range opts.Disabled {
+		printf("if err := pipeline.Disable(%q); err != nil {\nreturn err\n}\n\n", pass)
+	}
+
+	printf("machine, err := vm.WithSpecs(%d, %d)\nif err != nil {\nreturn err\n}\n\n", opts.CellSize, opts.TapeSize)
+	printf("machine.SetPipeline(pipeline)\n")
+	printf("machine.SetEOFMode(vm.%s)\n", eofModes[opts.EOFMode])
+	printf("machine.SetTapeMode(vm.%s)\n", tapeModes[opts.TapeMode])
+	printf("machine.SetOverflowMode(vm.%s)\n", overflowModes[opts.OverflowMode])
+	printf("machine.SetSigned(%v)\n", opts.Signed)
+	printf("machine.SetInputMode(vm.%s)\n", inputModes[opts.InputMode])
+	printf("machine.SetOutputMode(vm.%s)\n", outputModes[opts.OutputMode])
+	printf("machine.SetOutputDelimiter(%q)\n", opts.Delimiter)
+	printf("machine.SetBackend(vm.%s)\n\n", backends[opts.Backend])
+
+	printf("if err := machine.LoadFromStream(bytes.NewReader(source)); err != nil {\nreturn err\n}\n\n")
+	printf("return machine.Run()\n}\n")
+
+	return format.Source(b.Bytes())
+}
@@ -0,0 +1,175 @@
+package bundle_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen/bundle"
+	"github.com/ibraimgm/bfi/codegen/codegentest"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// localOptions returns options that build with this copy of bfi
+func localOptions(t *testing.T) bundle.Options {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := bundle.DefaultOptions()
+	opts.Module = root
+	return opts
+}
+
+func TestGenerate(t *testing.T) {
+	opts := localOptions(t)
+	opts.CellSize = 16
+	opts.EOFMode = vm.EOFZero
+	opts.OutputMode = vm.OutputDecimal
+	opts.Delimiter = ","
+	opts.Disabled = []string{"clear"}
+
+	dir := t.TempDir()
+	if err := bundle.Generate(dir, "echo.bf", []byte(",[.,]"), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{
+		"go.mod": {
+			"module bfprogram",
+			"require github.com/ibraimgm/bfi v0.0.0",
+			"replace github.com/ibraimgm/bfi => " + opts.Module,
+		},
+		"main.go": {
+			"// Code generated by bfi from echo.bf. DO NOT EDIT.",
+			"//go:embed program.bf",
+			`pipeline.Disable("clear")`,
+			"vm.WithSpecs(16, 3000)",
+			"machine.SetEOFMode(vm.EOFZero)",
+			"machine.SetOutputMode(vm.OutputDecimal)",
+			`machine.SetOutputDelimiter(",")`,
+		},
+		"program.bf": {",[.,]"},
+	}
+
+	for file, contents := range expected {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range contents {
+			if !strings.Contains(string(data), s) {
+				t.Errorf("File %v, expected \"%v\" in:\n%s", file, s, data)
+			}
+		}
+	}
+}
+
+func TestGenerateVersion(t *testing.T) {
+	opts := bundle.DefaultOptions()
+	opts.Module = "v1.2.3"
+
+	dir := t.TempDir()
+	if err := bundle.Generate(dir, "empty.bf", nil, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), "require github.com/ibraimgm/bfi v1.2.3") || strings.Contains(string(data), "replace") {
+		t.Errorf("expected a requirement of the published version:\n%s", data)
+	}
+}
+
+func TestReleaseVersion(t *testing.T) {
+	testCases := []struct {
+		version  string
+		expected string
+	}{
+		{version: "v1.2.3", expected: "v1.2.3"},
+		{version: "v1.2.3-rc.1", expected: "v1.2.3-rc.1"},
+		{version: "", expected: ""},
+		{version: "(devel)", expected: ""},
+		{version: "v1.2.3+dirty", expected: ""},
+		{version: "v0.0.0-20240102150405-0123456789ab", expected: ""},
+		{version: "v1.2.4-0.20240102150405-0123456789ab", expected: ""},
+		{version: "v1.2.4-rc.1.0.20240102150405-0123456789ab", expected: ""},
+		{version: "v0.0.0-20240102150405-0123456789ab+dirty", expected: ""},
+	}
+
+	for i, test := range testCases {
+		if version := bundle.ReleaseVersion(test.version); version != test.expected {
+			t.Errorf("Case %v, expected version of %q to be %q, but was %q", i, test.version, test.expected, version)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		change   func(opts *bundle.Options)
+		expected error
+	}{
+		{change: func(opts *bundle.Options) {}, expected: nil},
+		{change: func(opts *bundle.Options) { opts.Module = "" }, expected: bundle.ErrUnknownModule},
+		{change: func(opts *bundle.Options) { opts.EOFMode = 10 }, expected: vm.InvalidModeError{Kind: "EOF", Name: "10"}},
+		{change: func(opts *bundle.Options) { opts.Backend = -1 }, expected: vm.InvalidModeError{Kind: "backend", Name: "-1"}},
+	}
+
+	for i, test := range testCases {
+		opts := localOptions(t)
+		test.change(&opts)
+
+		if err := opts.Validate(); !errors.Is(err, test.expected) {
+			t.Errorf("Case %v, expected error '%v', but got '%v'", i, test.expected, err)
+		}
+	}
+}
+
+// TestBuildMatchesVM builds a few programs with the go command, and
+// compares them with the virtual machine
+func TestBuildMatchesVM(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building executables is slow")
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is not available")
+	}
+
+	names := map[string]bool{"hello8": true, "negative64": true, "eofminusone16": true, "eoferror": true}
+	dir := t.TempDir()
+
+	for i, test := range codegentest.Cases() {
+		if !names[test.Name] {
+			continue
+		}
+
+		src := filepath.Join(dir, test.Name+".bf")
+		bin := filepath.Join(dir, test.Name)
+
+		if err := os.WriteFile(src, []byte(test.Source), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		opts := localOptions(t)
+		opts.CellSize = test.Options.CellSize
+		opts.TapeSize = test.Options.TapeSize
+		opts.EOFMode = test.Options.EOFMode
+		opts.OutputMode = vm.OutputByte
+
+		if err := bundle.Build(src, bin, opts); err != nil {
+			t.Errorf("Case %v (%v), %v", i, test.Name, err)
+			continue
+		}
+
+		codegentest.Compare(t, test, exec.Command(bin))
+	}
+}
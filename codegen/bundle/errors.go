@@ -0,0 +1,26 @@
+package bundle
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownModule indicates that the version of bfi required by the
+// generated module is not known, as when bfi itself was built from a local
+// copy. In this case, a version or directory must be given in the options.
+var ErrUnknownModule = errors.New("unknown bfi module version; specify a version or a local directory")
+
+// BuildError indicates that the go command failed to build the executable.
+type BuildError struct {
+	Err    error
+	Output string
+}
+
+func (err *BuildError) Error() string {
+	return fmt.Sprintf("go build failed: %v\n%s", err.Err, err.Output)
+}
+
+// Unwrap returns the original error
+func (err *BuildError) Unwrap() error {
+	return err.Err
+}
@@ -0,0 +1,4 @@
+package bundle
+
+// ReleaseVersion returns the version if it names a tagged release
+var ReleaseVersion = releaseVersion
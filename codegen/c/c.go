@@ -0,0 +1,244 @@
+// Package c translates brainf*ck programs into self-contained C99 source code.
+package c
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// Options controls the generated C source
+type Options struct {
+	codegen.Options
+}
+
+type generator struct {
+	bytes.Buffer
+	opts   Options
+	indent int
+}
+
+// Emit writes the program as a C99 source file, that only depends on the
+// standard library
+func Emit(w io.Writer, program []parser.Instruction, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	program = opts.Prune(program)
+
+	g := &generator{opts: opts}
+	g.header(program)
+	g.main(program)
+
+	_, err := w.Write(g.Bytes())
+	return err
+}
+
+func (g *generator) header(program []parser.Instruction) {
+	g.line("/* %s */", codegen.Header(g.opts.Source))
+	g.line("")
+	g.line("#include <stdint.h>")
+	g.line("#include <stdio.h>")
+	g.line("#include <stdlib.h>")
+	g.line("")
+	g.line("#define TAPE_SIZE %d", g.opts.TapeSize)
+	g.line("")
+	g.line("typedef uint%d_t cell;", g.opts.CellSize)
+
+	if codegen.HasInput(program) {
+		g.line("")
+		g.readCell()
+	}
+}
+
+// readCell writes the function used by the input command
+func (g *generator) readCell() {
+	g.line("/* read_cell reads a byte into the cell, flushing the output first */")
+	g.line("static void read_cell(cell *c)")
+	g.line("{")
+	g.indent++
+	g.line("int ch;")
+	g.line("")
+	g.line("fflush(stdout);")
+	g.line("ch = getchar();")
+	g.line("")
+	g.line("if (ch != EOF) {")
+	g.indent++
+	g.line("*c = (cell)ch;")
+	g.indent--
+
+	switch g.opts.EOFMode {
+	case vm.EOFUnchanged:
+		g.line("}")
+	case vm.EOFZero:
+		g.block("} else {", "*c = 0;")
+	case vm.EOFMinusOne:
+		g.block("} else {", "*c = (cell)-1;")
+	default:
+		g.block("} else {", `fputs("ran out of input\n", stderr);`, "exit(1);")
+	}
+
+	g.indent--
+	g.line("}")
+}
+
+func (g *generator) main(program []parser.Instruction) {
+	g.line("")
+	g.line("int main(void)")
+	g.line("{")
+	g.indent++
+
+	// an unused tape or pointer would only cause warnings
+	if codegen.UsesTape(program) {
+		g.line("static cell tape[TAPE_SIZE];")
+		g.line("size_t p = 0;")
+		g.line("")
+
+		for _, ins := range program {
+			g.instruction(ins)
+		}
+
+		g.line("")
+	}
+
+	g.line("return 0;")
+	g.indent--
+	g.line("}")
+}
+
+func (g *generator) instruction(ins parser.Instruction) {
+	switch ins.Cmd {
+	case parser.CmdMove:
+		g.move(ins.Arg)
+
+	case parser.CmdAdd:
+		g.add(g.cell(ins.Offset), ins.Arg, "")
+
+	case parser.CmdClear:
+		g.line("%s = 0;", g.cell(ins.Offset))
+
+	case parser.CmdMulAdd:
+		g.add(g.cell(ins.Offset), ins.Arg, "tape[p]")
+
+	case parser.CmdScan:
+		g.line("while (tape[p]) {")
+		g.indent++
+		g.move(ins.Arg)
+		g.indent--
+		g.line("}")
+
+	case parser.CmdJump:
+		g.line("while (tape[p]) {")
+		g.indent++
+
+	case parser.CmdReturn:
+		g.indent--
+		g.line("}")
+
+	case parser.CmdInput:
+		g.line("read_cell(&tape[p]);")
+
+	case parser.CmdOutput:
+		g.line("putchar((unsigned char)tape[p]);")
+	}
+}
+
+// cell returns the expression of the cell at offset from the pointer
+func (g *generator) cell(offset int) string {
+	size := g.opts.TapeSize
+
+	switch offset = codegen.Wrap(offset, size); {
+	case offset == 0:
+		return "tape[p]"
+	case size-offset < offset:
+		return fmt.Sprintf("tape[(p + TAPE_SIZE - %d) %% TAPE_SIZE]", size-offset)
+	default:
+		return fmt.Sprintf("tape[(p + %d) %% TAPE_SIZE]", offset)
+	}
+}
+
+// move moves the pointer, wrapping around the ends of the tape. The pointer
+// is unsigned, so moving to the left adds the tape size first.
+func (g *generator) move(delta int) {
+	size := g.opts.TapeSize
+	delta = codegen.Wrap(delta, size)
+
+	switch {
+	case delta == 0:
+		return
+	case size-delta < delta:
+		g.line("p += TAPE_SIZE - %d;", size-delta)
+	case delta == 1:
+		g.line("p++;")
+	default:
+		g.line("p += %d;", delta)
+	}
+
+	g.line("if (p >= TAPE_SIZE)")
+	g.indent++
+	g.line("p -= TAPE_SIZE;")
+	g.indent--
+}
+
+// add adds delta times factor to the cell. An empty factor adds delta alone.
+// The products use unsigned constants, so they wrap around instead of
+// overflowing a signed int.
+func (g *generator) add(cell string, delta int, factor string) {
+	value := codegen.Truncate(delta, g.opts.CellSize)
+	op := "+"
+
+	if negated := codegen.Truncate(-delta, g.opts.CellSize); negated < value {
+		value, op = negated, "-"
+	}
+
+	switch {
+	case value == 0:
+		return
+	case factor == "" && value == 1:
+		g.line("%s%s%s;", cell, op, op)
+	case factor == "":
+		g.line("%s %s= %s;", cell, op, constant(value, false))
+	case value == 1:
+		g.line("%s %s= %s;", cell, op, factor)
+	default:
+		g.line("%s %s= %s * %s;", cell, op, factor, constant(value, true))
+	}
+}
+
+// constant returns the C literal of the value. Values that do not fit in an
+// int are always unsigned.
+func constant(value uint64, unsigned bool) string {
+	if unsigned || value > 0x7FFFFFFF {
+		return fmt.Sprintf("%du", value)
+	}
+
+	return fmt.Sprintf("%d", value)
+}
+
+// block writes the opening line, followed by the indented lines
+func (g *generator) block(open string, lines ...string) {
+	g.line(open)
+	g.indent++
+
+	for _, l := range lines {
+		g.line("%s", l)
+	}
+
+	g.indent--
+	g.line("}")
+}
+
+func (g *generator) line(format string, args ...interface{}) {
+	if format != "" {
+		g.WriteString(strings.Repeat("\t", g.indent))
+		fmt.Fprintf(g, format, args...)
+	}
+
+	g.WriteByte('\n')
+}
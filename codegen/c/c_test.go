@@ -0,0 +1,85 @@
+package c_test
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/codegen/c"
+	"github.com/ibraimgm/bfi/codegen/codegentest"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+func TestEmitEOFModes(t *testing.T) {
+	testCases := []struct {
+		mode     vm.EOFMode
+		expected string
+	}{
+		{mode: vm.EOFError, expected: "exit(1);"},
+		{mode: vm.EOFZero, expected: "*c = 0;"},
+		{mode: vm.EOFMinusOne, expected: "*c = (cell)-1;"},
+	}
+
+	program, err := codegentest.Program(",.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, test := range testCases {
+		opts := c.Options{Options: codegentest.Cases()[0].Options}
+		opts.EOFMode = test.mode
+
+		var buf bytes.Buffer
+		if err := c.Emit(&buf, program, opts); err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		if !strings.Contains(buf.String(), test.expected) {
+			t.Errorf("Case %v, expected \"%v\" in the generated code:\n%s", i, test.expected, buf.String())
+		}
+	}
+}
+
+func TestEmitWithoutEffects(t *testing.T) {
+	program, err := codegentest.Program(strings.Repeat("+", 256) + ">>>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := c.Options{Options: codegentest.Cases()[0].Options}
+	opts.TapeSize = 3
+
+	var buf bytes.Buffer
+	if err := c.Emit(&buf, program, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// an unused tape or pointer makes -Wall warn
+	if strings.Contains(buf.String(), "tape[") {
+		t.Errorf("Expected no tape in the generated code:\n%s", buf.String())
+	}
+}
+
+// TestEmitMatchesVM compiles the generated programs with the local C
+// compiler, and compares them with the virtual machine
+func TestEmitMatchesVM(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler available")
+	}
+
+	emit := func(w io.Writer, program []parser.Instruction, opts codegen.Options) error {
+		return c.Emit(w, program, c.Options{Options: opts})
+	}
+
+	codegentest.Run(t, ".c", emit, func(_ codegentest.Case, src string) (*exec.Cmd, error) {
+		bin := strings.TrimSuffix(src, ".c")
+		cmd := exec.Command(cc, "-std=c99", "-pedantic", "-Wall", "-Wextra", "-Werror", "-O2", "-o", bin, src)
+
+		return exec.Command(bin), codegentest.Exec(cmd)
+	})
+}
@@ -0,0 +1,136 @@
+// Package codegen holds what is shared by the code generators, that translate
+// brainf*ck programs into other languages.
+//
+// The generated programs follow the default semantics of the virtual machine:
+// the cell arithmetic wraps around, and so does the tape. Input and output are
+// made of single bytes, as with vm.InputByte and vm.OutputByte, and the
+// behavior at the end of the input follows the EOF mode.
+package codegen
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// Options are the settings of the generated program
+type Options struct {
+	CellSize int
+	TapeSize int
+	EOFMode  vm.EOFMode
+
+	// Source is the name of the brainf*ck source file, used in the header
+	// comment. It is optional.
+	Source string
+}
+
+// DefaultOptions returns the same settings used by a new virtual machine
+func DefaultOptions() Options {
+	return Options{CellSize: 8, TapeSize: 3000, EOFMode: vm.EOFError}
+}
+
+// Validate checks if the options can be used to generate code
+func (opts Options) Validate() error {
+	if err := vm.CheckCellSize(opts.CellSize); err != nil {
+		return err
+	}
+
+	if opts.TapeSize <= 0 {
+		return InvalidTapeSizeError(opts.TapeSize)
+	}
+
+	return nil
+}
+
+// Load parses the source code and runs the optimizer pipeline on it. A nil
+// pipeline leaves the program as parsed.
+//
+// The jump and return instructions are not linked; as every bracket is
+// matched, the generators can rely on their nesting instead.
+func Load(source io.Reader, pipeline *optimizer.Pipeline) ([]parser.Instruction, error) {
+	program, err := parser.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if pipeline != nil {
+		program = pipeline.Run(program)
+	}
+
+	return program, nil
+}
+
+// Header returns the text of the comment at the start of the generated code,
+// that marks it as generated from the source file
+func Header(source string) string {
+	if source == "" {
+		return "Code generated by bfi. DO NOT EDIT."
+	}
+
+	return fmt.Sprintf("Code generated by bfi from %s. DO NOT EDIT.", source)
+}
+
+// Wrap returns delta reduced to the range [0, size), which moves the pointer
+// to the same cell of a tape that wraps around
+func Wrap(delta int, size int) int {
+	return (delta%size + size) % size
+}
+
+// Truncate returns the value reduced to the specified number of bits, as
+// the cell arithmetic does
+func Truncate(value int, bits int) uint64 {
+	return uint64(value) & (^uint64(0) >> uint(64-bits))
+}
+
+// HasInput returns true if the program reads any input
+func HasInput(program []parser.Instruction) bool {
+	for _, ins := range program {
+		if ins.Cmd == parser.CmdInput {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Prune returns the program without the instructions that have no effect
+// with these options: additions and multiplications that truncate to zero, and
+// moves around the whole tape. The generators emit nothing for them, so they
+// must be left out before checking if the program uses the tape.
+func (opts Options) Prune(program []parser.Instruction) []parser.Instruction {
+	result := make([]parser.Instruction, 0, len(program))
+
+	for _, ins := range program {
+		switch ins.Cmd {
+		case parser.CmdAdd, parser.CmdMulAdd:
+			if Truncate(ins.Arg, opts.CellSize) == 0 {
+				continue
+			}
+
+		case parser.CmdMove:
+			if Wrap(ins.Arg, opts.TapeSize) == 0 {
+				continue
+			}
+		}
+
+		result = append(result, ins)
+	}
+
+	return result
+}
+
+// UsesTape returns true if the program does anything other than moving the
+// pointer. Moves alone have no visible effect, so the generated code for such
+// programs may leave the tape out.
+func UsesTape(program []parser.Instruction) bool {
+	for _, ins := range program {
+		if ins.Cmd != parser.CmdMove {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,177 @@
+// Package codegentest helps testing the code generators, by comparing the
+// behavior of the generated programs with the virtual machine.
+package codegentest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// Case is a program to be translated, along with its input
+type Case struct {
+	Name    string
+	Source  string
+	Input   string
+	Options codegen.Options
+}
+
+func withOptions(cellSize int, tapeSize int, eofMode vm.EOFMode) codegen.Options {
+	return codegen.Options{CellSize: cellSize, TapeSize: tapeSize, EOFMode: eofMode}
+}
+
+const hello = "++++++++[>++++[>++>+++>+++>+<<<<-]>+>+>->>+[<]<-]>>.>---.+++++++..+++.>>.<-.<.+++.------.--------.>>+.>++."
+
+// Cases returns programs that use every instruction, cell size and EOF mode,
+// and that stop in a reasonable time with any cell size
+func Cases() []Case {
+	return []Case{
+		{Name: "hello8", Source: hello, Options: withOptions(8, 3000, vm.EOFError)},
+		{Name: "hello16", Source: hello, Options: withOptions(16, 3000, vm.EOFError)},
+		{Name: "hello32", Source: hello, Options: withOptions(32, 3000, vm.EOFError)},
+		{Name: "hello64", Source: hello, Options: withOptions(64, 3000, vm.EOFError)},
+		{Name: "wrap8", Source: "+[-->-[>>+>-----<<]<--<---]>-.>>>+.>>..+++[.>]<<<<.+++.------.<<-.>>>>+.", Options: withOptions(8, 3000, vm.EOFError)},
+		{Name: "negative", Source: "-.>--.>+++[->------<]>.", Options: withOptions(8, 3000, vm.EOFError)},
+		{Name: "negative16", Source: "-.>--.>+++[->------<]>.", Options: withOptions(16, 3000, vm.EOFError)},
+		{Name: "negative64", Source: "-.>--.>+++[->------<]>.", Options: withOptions(64, 3000, vm.EOFError)},
+		{Name: "wide", Source: "++++++++[>++++++++<-]>[>++++<-]>.>+++[<++>-]<.", Options: withOptions(16, 3000, vm.EOFError)},
+		{Name: "scan", Source: "+>+>+>+>>+<<<<<[>]+>>>>>>+>+>>+<<[>>]>+[<<<]+[.>]", Options: withOptions(8, 3000, vm.EOFError)},
+		{Name: "tapewrap", Source: "+<<+++[>>>>++<<<<-]>>>>.<<<<<.>>>>>>>>>>+.[<]<.", Options: withOptions(8, 4, vm.EOFError)},
+		{Name: "offsetwrap", Source: ">+++[<<<++>>>-]<<<.>>>>>>>>>[-]>+[<]>.", Options: withOptions(32, 5, vm.EOFError)},
+		{Name: "eofzero", Source: ",[.,]", Input: "echo me", Options: withOptions(8, 3000, vm.EOFZero)},
+		{Name: "eofminusone", Source: ",+[-.,+]", Input: "abc", Options: withOptions(8, 3000, vm.EOFMinusOne)},
+		{Name: "eofminusone16", Source: ",+[-.,+]", Input: "abc", Options: withOptions(16, 3000, vm.EOFMinusOne)},
+		{Name: "eofminusone64", Source: ",+[-.,+]", Input: "abc", Options: withOptions(64, 3000, vm.EOFMinusOne)},
+		{Name: "eofunchanged", Source: ",.,.,.", Input: "a", Options: withOptions(8, 3000, vm.EOFUnchanged)},
+		{Name: "eoferror", Source: ",.,.,.", Input: "a", Options: withOptions(8, 3000, vm.EOFError)},
+		{Name: "empty", Source: "", Options: withOptions(8, 3000, vm.EOFError)},
+		{Name: "moves", Source: ">><", Options: withOptions(8, 3000, vm.EOFError)},
+		{Name: "foldaway", Source: strings.Repeat("+", 256) + ">", Options: withOptions(8, 3000, vm.EOFError)},
+	}
+}
+
+// Program parses and optimizes the source code, as done by the command line
+func Program(source string) ([]parser.Instruction, error) {
+	pipeline, err := optimizer.New(optimizer.MaxLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	return codegen.Load(strings.NewReader(source), pipeline)
+}
+
+// Expected runs the case in the virtual machine, returning what it writes
+// and whether it fails
+func Expected(c Case) (string, bool, error) {
+	machine, err := vm.WithSpecs(c.Options.CellSize, c.Options.TapeSize)
+	if err != nil {
+		return "", false, err
+	}
+
+	machine.SetEOFMode(c.Options.EOFMode)
+	machine.SetOutputMode(vm.OutputByte)
+
+	if err := machine.LoadFromString(c.Source); err != nil {
+		return "", false, err
+	}
+
+	out := strings.Builder{}
+	machine.SetIO(strings.NewReader(c.Input), &out)
+
+	failed := machine.Run() != nil
+	return out.String(), failed, nil
+}
+
+// Compare runs the command with the input of the case, and reports an error
+// if its output or exit status does not match the virtual machine
+func Compare(t *testing.T, c Case, cmd *exec.Cmd) {
+	t.Helper()
+
+	expected, expectedFail, err := Expected(c)
+	if err != nil {
+		t.Fatalf("%v: %v", c.Name, err)
+	}
+
+	cmd.Stdin = strings.NewReader(c.Input)
+	output, err := cmd.Output()
+
+	var exitErr *exec.ExitError
+	failed := errors.As(err, &exitErr)
+
+	if err != nil && !failed {
+		t.Fatalf("%v: %v", c.Name, err)
+	}
+
+	if string(output) != expected {
+		t.Errorf("%v: output mismatch. Expected %q, received %q", c.Name, expected, output)
+	}
+
+	if failed != expectedFail {
+		t.Errorf("%v: expected failure to be %v, but was %v", c.Name, expectedFail, failed)
+	}
+}
+
+// Emitter writes the program of a case, in the language of a code generator
+type Emitter func(w io.Writer, program []parser.Instruction, opts codegen.Options) error
+
+// Builder builds the file written by an Emitter, returning the command that
+// runs it. A nil command skips the case.
+type Builder func(c Case, src string) (*exec.Cmd, error)
+
+// Run translates every case into a file with the extension ext, in a
+// temporary directory, builds it and compares the resulting program with the
+// virtual machine
+func Run(t *testing.T, ext string, emit Emitter, build Builder) {
+	t.Helper()
+	dir := t.TempDir()
+
+	for i, c := range Cases() {
+		program, err := Program(c.Source)
+		if err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		opts := c.Options
+		opts.Source = c.Name + ".bf"
+
+		var buf bytes.Buffer
+		if err := emit(&buf, program, opts); err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		src := filepath.Join(dir, c.Name+ext)
+		if err := os.WriteFile(src, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd, err := build(c, src)
+		if err != nil {
+			t.Errorf("Case %v (%v), %v\n%s", i, c.Name, err, buf.String())
+			continue
+		}
+
+		if cmd != nil {
+			Compare(t, c, cmd)
+		}
+	}
+}
+
+// Exec runs a build step, returning an error with its output when it fails
+func Exec(cmd *exec.Cmd) error {
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v failed: %v\n%s", filepath.Base(cmd.Path), err, output)
+	}
+
+	return nil
+}
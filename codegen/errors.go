@@ -0,0 +1,12 @@
+package codegen
+
+import (
+	"fmt"
+)
+
+// InvalidTapeSizeError indicates that a wrong tape size was specified.
+type InvalidTapeSizeError int
+
+func (err InvalidTapeSizeError) Error() string {
+	return fmt.Sprintf("invalid tape size: %v", int(err))
+}
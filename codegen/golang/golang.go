@@ -0,0 +1,225 @@
+// Package golang translates brainf*ck programs into Go source code, with no
+// dependency on bfi.
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// Options controls the generated Go source
+type Options struct {
+	codegen.Options
+
+	// Package is the name of the generated package. For "main" (or an empty
+	// name), the program runs from the main function, reading from the
+	// standard input and writing to the standard output. Any other package
+	// exports the program as:
+	//
+	//	func Run(in io.Reader, out io.Writer) error
+	Package string
+}
+
+type generator struct {
+	bytes.Buffer
+	opts     Options
+	cellType string
+}
+
+// Emit writes the program as gofmt'd Go source code
+func Emit(w io.Writer, program []parser.Instruction, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	if opts.Package == "" {
+		opts.Package = "main"
+	}
+
+	program = opts.Prune(program)
+
+	g := &generator{opts: opts, cellType: fmt.Sprintf("uint%d", opts.CellSize)}
+	g.header(program)
+	g.body(program)
+
+	src, err := format.Source(g.Bytes())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(src)
+	return err
+}
+
+func (g *generator) header(program []parser.Instruction) {
+	g.printf("// %s\n\n", codegen.Header(g.opts.Source))
+	g.printf("package %s\n\n", g.opts.Package)
+	g.printf("import (\n\"bufio\"\n\"io\"\n")
+
+	if g.opts.Package == "main" {
+		g.printf("\"fmt\"\n\"os\"\n")
+	}
+
+	g.printf(")\n\n")
+	g.printf("const tapeSize = %d\n\n", g.opts.TapeSize)
+
+	entry := "Run"
+
+	if g.opts.Package == "main" {
+		entry = "run"
+		g.printf("func main() {\n")
+		g.printf("if err := run(os.Stdin, os.Stdout); err != nil {\n")
+		g.printf("fmt.Fprintln(os.Stderr, err)\nos.Exit(1)\n}\n}\n\n")
+	}
+
+	g.printf("// %s runs the program, reading its input from in and writing its output to out\n", entry)
+	g.printf("func %s(in io.Reader, out io.Writer) error {\n", entry)
+	g.printf("w := bufio.NewWriter(out)\n")
+	g.printf("err := execute(bufio.NewReader(in), w)\n\n")
+	g.printf("if flushErr := w.Flush(); err == nil {\nerr = flushErr\n}\n\n")
+	g.printf("return err\n}\n\n")
+
+	if codegen.HasInput(program) {
+		g.printf("// readByte flushes the pending output before waiting for input\n")
+		g.printf("func readByte(r *bufio.Reader, w *bufio.Writer) (byte, error) {\n")
+		g.printf("if r.Buffered() == 0 {\nif err := w.Flush(); err != nil {\nreturn 0, err\n}\n}\n\n")
+		g.printf("return r.ReadByte()\n}\n\n")
+	}
+}
+
+func (g *generator) body(program []parser.Instruction) {
+	g.printf("func execute(r *bufio.Reader, w *bufio.Writer) error {\n")
+
+	// an unused tape or pointer would not compile
+	if codegen.UsesTape(program) {
+		g.printf("tape := make([]%s, tapeSize)\n", g.cellType)
+		g.printf("p := 0\n\n")
+
+		for _, ins := range program {
+			g.instruction(ins)
+		}
+	}
+
+	g.printf("\nreturn nil\n}\n")
+}
+
+func (g *generator) instruction(ins parser.Instruction) {
+	switch ins.Cmd {
+	case parser.CmdMove:
+		g.move(ins.Arg)
+
+	case parser.CmdAdd:
+		g.add(g.cell(ins.Offset), ins.Arg, "")
+
+	case parser.CmdClear:
+		g.printf("%s = 0\n", g.cell(ins.Offset))
+
+	case parser.CmdMulAdd:
+		g.add(g.cell(ins.Offset), ins.Arg, "tape[p]")
+
+	case parser.CmdScan:
+		g.printf("for tape[p] != 0 {\n")
+		g.move(ins.Arg)
+		g.printf("}\n")
+
+	case parser.CmdJump:
+		g.printf("for tape[p] != 0 {\n")
+
+	case parser.CmdReturn:
+		g.printf("}\n")
+
+	case parser.CmdInput:
+		g.input()
+
+	case parser.CmdOutput:
+		g.printf("if err := w.WriteByte(byte(tape[p])); err != nil {\nreturn err\n}\n")
+	}
+}
+
+// cell returns the expression of the cell at offset from the pointer
+func (g *generator) cell(offset int) string {
+	size := g.opts.TapeSize
+
+	switch offset = codegen.Wrap(offset, size); {
+	case offset == 0:
+		return "tape[p]"
+	case size-offset < offset:
+		return fmt.Sprintf("tape[(p+tapeSize-%d)%%tapeSize]", size-offset)
+	default:
+		return fmt.Sprintf("tape[(p+%d)%%tapeSize]", offset)
+	}
+}
+
+// move moves the pointer, wrapping around the ends of the tape
+func (g *generator) move(delta int) {
+	size := g.opts.TapeSize
+	delta = codegen.Wrap(delta, size)
+
+	switch {
+	case delta == 0:
+		return
+	case size-delta < delta:
+		g.printf("%s\nif p < 0 {\np += tapeSize\n}\n", step("p", "-", uint64(size-delta)))
+	default:
+		g.printf("%s\nif p >= tapeSize {\np -= tapeSize\n}\n", step("p", "+", uint64(delta)))
+	}
+}
+
+// add adds delta times factor to the cell. An empty factor adds
+// delta alone.
+func (g *generator) add(cell string, delta int, factor string) {
+	value := codegen.Truncate(delta, g.opts.CellSize)
+	op := "+"
+
+	if negated := codegen.Truncate(-delta, g.opts.CellSize); negated < value {
+		value, op = negated, "-"
+	}
+
+	switch {
+	case value == 0:
+		return
+	case factor == "":
+		g.printf("%s\n", step(cell, op, value))
+	case value == 1:
+		g.printf("%s %s= %s\n", cell, op, factor)
+	default:
+		g.printf("%s %s= %s * %d\n", cell, op, factor, value)
+	}
+}
+
+// step returns the statement that adds or subtracts value from v
+func step(v string, op string, value uint64) string {
+	if value == 1 {
+		return v + op + op
+	}
+
+	return fmt.Sprintf("%s %s= %d", v, op, value)
+}
+
+func (g *generator) input() {
+	g.printf("switch b, err := readByte(r, w); {\n")
+	g.printf("case err == nil:\ntape[p] = %s(b)\n", g.cellType)
+
+	switch g.opts.EOFMode {
+	case vm.EOFUnchanged:
+		g.printf("case err == io.EOF:\n// the cell is left unchanged\n")
+	case vm.EOFZero:
+		g.printf("case err == io.EOF:\ntape[p] = 0\n")
+	case vm.EOFMinusOne:
+		g.printf("case err == io.EOF:\ntape[p] = ^%s(0)\n", g.cellType)
+	default:
+		g.printf("case err == io.EOF:\nreturn io.ErrUnexpectedEOF\n")
+	}
+
+	g.printf("default:\nreturn err\n}\n")
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g, format, args...)
+}
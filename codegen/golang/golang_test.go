@@ -0,0 +1,76 @@
+package golang_test
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/codegen/codegentest"
+	"github.com/ibraimgm/bfi/codegen/golang"
+	bfparser "github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+func TestEmitPackage(t *testing.T) {
+	program, err := codegentest.Program(",[.,]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := golang.Options{Options: codegentest.Cases()[0].Options, Package: "echo"}
+	var buf bytes.Buffer
+
+	if err := golang.Emit(&buf, program, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "echo.go", buf.Bytes(), 0)
+	if err != nil {
+		t.Fatalf("Generated code does not parse: %v\n%s", err, buf.String())
+	}
+
+	if file.Name.Name != "echo" {
+		t.Errorf("Expected package \"echo\", received \"%v\"", file.Name.Name)
+	}
+
+	if file.Scope.Lookup("Run") == nil {
+		t.Errorf("Expected the Run function to be declared")
+	}
+
+	if file.Scope.Lookup("main") != nil {
+		t.Errorf("Expected no main function")
+	}
+}
+
+// TestEmitMatchesVM builds the generated programs with the local go
+// command, and compares them with the virtual machine
+func TestEmitMatchesVM(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building the generated programs takes a while")
+	}
+
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is not available")
+	}
+
+	emit := func(w io.Writer, program []bfparser.Instruction, opts codegen.Options) error {
+		return golang.Emit(w, program, golang.Options{Options: opts})
+	}
+
+	codegentest.Run(t, ".go", emit, func(c codegentest.Case, src string) (*exec.Cmd, error) {
+		bin := strings.TrimSuffix(src, ".go")
+
+		cmd := exec.Command(goCmd, "build", "-o", bin, src)
+		cmd.Dir = filepath.Dir(src)
+		cmd.Env = append(os.Environ(), "GO111MODULE=off")
+
+		return exec.Command(bin), codegentest.Exec(cmd)
+	})
+}
@@ -0,0 +1,245 @@
+// Package js translates brainf*ck programs into JavaScript ES modules, that
+// run in browsers and in Node.js with no interpreter.
+//
+// The module exports two functions:
+//
+//	run(input: Uint8Array): Uint8Array
+//	runStream(readByte: () => number, writeByte: (byte: number) => void): void
+//
+// readByte returns -1 at the end of the input. When the program runs out of
+// input with vm.EOFError, both functions throw an Error.
+package js
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// Options controls the generated module
+type Options struct {
+	codegen.Options
+}
+
+// arrays maps the cell sizes to the typed arrays used as tape
+var arrays = map[int]string{
+	8:  "Uint8Array",
+	16: "Uint16Array",
+	32: "Uint32Array",
+	64: "BigUint64Array",
+}
+
+type generator struct {
+	bytes.Buffer
+	opts   Options
+	indent int
+	bigint bool
+}
+
+// Emit writes the program as an ES module
+func Emit(w io.Writer, program []parser.Instruction, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	g := &generator{opts: opts, bigint: opts.CellSize == 64}
+	g.header()
+	g.runStream(program)
+	g.run()
+
+	_, err := w.Write(g.Bytes())
+	return err
+}
+
+func (g *generator) header() {
+	g.line("// %s", codegen.Header(g.opts.Source))
+	g.line("")
+	g.line("const TAPE_SIZE = %d;", g.opts.TapeSize)
+}
+
+func (g *generator) runStream(program []parser.Instruction) {
+	g.line("")
+	g.line("/**")
+	g.line(" * Runs the program, reading and writing one byte at a time.")
+	g.line(" *")
+	g.line(" * @param {() => number} readByte returns the next byte of input, or -1 at the end")
+	g.line(" * @param {(byte: number) => void} writeByte receives each byte of output")
+	g.line(" */")
+	g.line("export function runStream(readByte, writeByte) {")
+	g.indent++
+	g.line("const tape = new %s(TAPE_SIZE);", arrays[g.opts.CellSize])
+	g.line("let p = 0;")
+
+	if codegen.HasInput(program) {
+		g.readCell()
+	}
+
+	if len(program) > 0 {
+		g.line("")
+	}
+
+	for _, ins := range program {
+		g.instruction(ins)
+	}
+
+	g.indent--
+	g.line("}")
+}
+
+func (g *generator) run() {
+	g.line("")
+	g.line("/**")
+	g.line(" * Runs the program with the whole input at once.")
+	g.line(" *")
+	g.line(" * @param {Uint8Array} input")
+	g.line(" * @returns {Uint8Array} the output of the program")
+	g.line(" */")
+	g.line("export function run(input) {")
+	g.indent++
+	g.line("const output = [];")
+	g.line("let pos = 0;")
+	g.line("")
+	g.line("runStream(")
+	g.indent++
+	g.line("() => (pos < input.length ? input[pos++] : -1),")
+	g.line("(byte) => output.push(byte),")
+	g.indent--
+	g.line(");")
+	g.line("")
+	g.line("return Uint8Array.from(output);")
+	g.indent--
+	g.line("}")
+}
+
+func (g *generator) instruction(ins parser.Instruction) {
+	switch ins.Cmd {
+	case parser.CmdMove:
+		g.move(ins.Arg)
+
+	case parser.CmdAdd:
+		if value := g.constant(ins.Arg); value != g.constant(0) {
+			g.line("%s += %s;", g.cell(ins.Offset), value)
+		}
+
+	case parser.CmdClear:
+		g.line("%s = %s;", g.cell(ins.Offset), g.constant(0))
+
+	case parser.CmdMulAdd:
+		// Math.imul keeps the low bits of large products, that doubles would lose
+		if g.bigint {
+			g.line("%s += tape[p] * %s;", g.cell(ins.Offset), g.constant(ins.Arg))
+		} else {
+			g.line("%s += Math.imul(tape[p], %s);", g.cell(ins.Offset), g.constant(ins.Arg))
+		}
+
+	case parser.CmdScan:
+		g.line("while (tape[p]) {")
+		g.indent++
+		g.move(ins.Arg)
+		g.indent--
+		g.line("}")
+
+	case parser.CmdJump:
+		g.line("while (tape[p]) {")
+		g.indent++
+
+	case parser.CmdReturn:
+		g.indent--
+		g.line("}")
+
+	case parser.CmdInput:
+		g.line("tape[p] = readCell(tape[p]);")
+
+	case parser.CmdOutput:
+		if g.bigint {
+			g.line("writeByte(Number(tape[p] & 0xffn));")
+		} else {
+			g.line("writeByte(tape[p] & 0xff);")
+		}
+	}
+}
+
+// cell returns the expression of the cell at offset from the pointer
+func (g *generator) cell(offset int) string {
+	if offset = codegen.Wrap(offset, g.opts.TapeSize); offset == 0 {
+		return "tape[p]"
+	}
+
+	return fmt.Sprintf("tape[(p + %d) %% TAPE_SIZE]", offset)
+}
+
+// move moves the pointer, wrapping around the end of the tape
+func (g *generator) move(delta int) {
+	switch delta = codegen.Wrap(delta, g.opts.TapeSize); delta {
+	case 0:
+		return
+	case 1:
+		g.line("if (++p === TAPE_SIZE) p = 0;")
+	default:
+		g.line("p += %d;", delta)
+		g.line("if (p >= TAPE_SIZE) p -= TAPE_SIZE;")
+	}
+}
+
+// constant returns the literal of the value, truncated to the cell size and
+// read as a signed number. The typed arrays wrap the values stored into them.
+func (g *generator) constant(value int) string {
+	bits := uint(g.opts.CellSize)
+	signed := int64(codegen.Truncate(value, g.opts.CellSize)<<(64-bits)) >> (64 - bits)
+
+	if g.bigint {
+		return fmt.Sprintf("%dn", signed)
+	}
+
+	return fmt.Sprint(signed)
+}
+
+// readCell writes the function used by the input command
+func (g *generator) readCell() {
+	g.line("")
+	g.line("// readCell returns the new value of a cell, after reading a byte")
+	g.line("const readCell = (cell) => {")
+	g.indent++
+	g.line("const byte = readByte();")
+	g.line("")
+	g.line("if (byte >= 0) {")
+	g.indent++
+
+	if g.bigint {
+		g.line("return BigInt(byte);")
+	} else {
+		g.line("return byte;")
+	}
+
+	g.indent--
+	g.line("}")
+	g.line("")
+
+	switch g.opts.EOFMode {
+	case vm.EOFUnchanged:
+		g.line("return cell;")
+	case vm.EOFZero:
+		g.line("return %s;", g.constant(0))
+	case vm.EOFMinusOne:
+		g.line("return %s;", g.constant(-1))
+	default:
+		g.line("throw new Error(\"ran out of input\");")
+	}
+
+	g.indent--
+	g.line("};")
+}
+
+func (g *generator) line(format string, args ...interface{}) {
+	if format != "" {
+		g.WriteString(strings.Repeat("  ", g.indent))
+		fmt.Fprintf(g, format, args...)
+	}
+
+	g.WriteByte('\n')
+}
@@ -0,0 +1,107 @@
+package js_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/codegen/codegentest"
+	"github.com/ibraimgm/bfi/codegen/js"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+func TestEmitTapeArrays(t *testing.T) {
+	testCases := []struct {
+		cellSize int
+		expected []string
+	}{
+		{cellSize: 8, expected: []string{"new Uint8Array(TAPE_SIZE)", "tape[p] += -1;", "return byte;"}},
+		{cellSize: 16, expected: []string{"new Uint16Array(TAPE_SIZE)", "tape[p] += -1;", "return byte;"}},
+		{cellSize: 32, expected: []string{"new Uint32Array(TAPE_SIZE)", "tape[p] += -1;", "return byte;"}},
+		{cellSize: 64, expected: []string{"new BigUint64Array(TAPE_SIZE)", "tape[p] += -1n;", "return BigInt(byte);", "0xffn"}},
+	}
+
+	program, err := codegentest.Program("-,.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, test := range testCases {
+		opts := js.Options{Options: codegen.Options{CellSize: test.cellSize, TapeSize: 30, EOFMode: vm.EOFError}}
+
+		var buf bytes.Buffer
+		if err := js.Emit(&buf, program, opts); err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		for _, s := range test.expected {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("Case %v, expected \"%v\" in the generated code:\n%s", i, s, buf.String())
+			}
+		}
+	}
+}
+
+// harness runs a module with node, through runStream or run, reading its
+// input from stdin and writing its output to stdout
+const harness = `
+import { readFileSync, writeSync } from "node:fs";
+import { pathToFileURL } from "node:url";
+
+const { run, runStream } = await import(pathToFileURL(process.argv[2]));
+const input = readFileSync(0);
+const output = [];
+let pos = 0;
+let status = 0;
+
+try {
+  if (process.argv[3] === "run") {
+    output.push(...run(new Uint8Array(input)));
+  } else {
+    runStream(() => (pos < input.length ? input[pos++] : -1), (b) => output.push(b));
+  }
+} catch (err) {
+  status = 1;
+}
+
+writeSync(1, Uint8Array.from(output));
+process.exit(status);
+`
+
+// TestEmitMatchesVM runs the generated modules with node, and compares them
+// with the virtual machine
+func TestEmitMatchesVM(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not available")
+	}
+
+	script := filepath.Join(t.TempDir(), "harness.mjs")
+
+	if err := os.WriteFile(script, []byte(harness), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	emit := func(w io.Writer, program []parser.Instruction, opts codegen.Options) error {
+		return js.Emit(w, program, js.Options{Options: opts})
+	}
+
+	codegentest.Run(t, ".mjs", emit, func(_ codegentest.Case, module string) (*exec.Cmd, error) {
+		return exec.Command(node, script, module, "stream"), nil
+	})
+
+	codegentest.Run(t, ".mjs", emit, func(c codegentest.Case, module string) (*exec.Cmd, error) {
+		// run gives no output when the program fails
+		if _, failed, err := codegentest.Expected(c); err != nil || failed {
+			return nil, err
+		}
+
+		return exec.Command(node, script, module, "run"), nil
+	})
+}
@@ -0,0 +1,254 @@
+// Package llvm translates brainf*ck programs into LLVM IR, in the textual
+// form accepted by llc, opt and clang.
+//
+// The generated module uses opaque pointers, so it needs LLVM 15 or later
+// (LLVM 14 accepts it with -opaque-pointers). It defines a main function
+// that uses getchar and putchar from the C library, with a tape of iN cells
+// matching the cell size.
+package llvm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// Options controls the generated module
+type Options struct {
+	codegen.Options
+}
+
+const eofMessage = "ran out of input\n"
+
+type generator struct {
+	bytes.Buffer
+	opts     Options
+	cell     string // type of the cells
+	tapeType string
+	loops    []int
+	labels   int
+	temps    int
+}
+
+// Emit writes the program as an LLVM module
+func Emit(w io.Writer, program []parser.Instruction, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	g := &generator{opts: opts, cell: fmt.Sprintf("i%d", opts.CellSize)}
+	g.tapeType = fmt.Sprintf("[%d x %s]", opts.TapeSize, g.cell)
+	g.header(program)
+	g.main(program)
+
+	_, err := w.Write(g.Bytes())
+	return err
+}
+
+func (g *generator) header(program []parser.Instruction) {
+	g.printf("; %s\n", codegen.Header(g.opts.Source))
+	g.printf("\n@tape = internal global %s zeroinitializer\n", g.tapeType)
+
+	if codegen.HasInput(program) && g.opts.EOFMode == vm.EOFError {
+		g.printf("@eof_message = private constant [%d x i8] c\"ran out of input\\0A\"\n", len(eofMessage))
+	}
+
+	g.printf("\ndeclare i32 @getchar()\n")
+	g.printf("declare i32 @putchar(i32)\n")
+
+	if codegen.HasInput(program) && g.opts.EOFMode == vm.EOFError {
+		g.printf("declare i32 @fflush(ptr)\n")
+		g.printf("declare i64 @write(i32, ptr, i64)\n")
+		g.printf("declare void @exit(i32) noreturn\n")
+	}
+}
+
+func (g *generator) main(program []parser.Instruction) {
+	g.printf("\ndefine i32 @main() {\n")
+	g.printf("entry:\n")
+	g.printf("  %%p = alloca i64\n")
+	g.printf("  store i64 0, ptr %%p\n")
+
+	line := 0
+
+	for _, ins := range program {
+		if ins.Span.Start.Line != line {
+			line = ins.Span.Start.Line
+			g.printf("\n  ; line %d\n", line)
+		}
+
+		g.printf("  ; %v: %v\n", ins.Span, ins)
+		g.instruction(ins)
+	}
+
+	g.printf("\n  ret i32 0\n")
+	g.printf("}\n")
+}
+
+func (g *generator) instruction(ins parser.Instruction) {
+	switch ins.Cmd {
+	case parser.CmdMove:
+		g.move(ins.Arg)
+
+	case parser.CmdAdd:
+		ptr := g.address(ins.Offset)
+		value := g.load(ptr)
+		g.store(g.op("add %s %s, %s", g.cell, value, g.constant(ins.Arg)), ptr)
+
+	case parser.CmdClear:
+		g.store("0", g.address(ins.Offset))
+
+	case parser.CmdMulAdd:
+		factor := g.op("mul %s %s, %s", g.cell, g.load(g.address(0)), g.constant(ins.Arg))
+		ptr := g.address(ins.Offset)
+		value := g.load(ptr)
+		g.store(g.op("add %s %s, %s", g.cell, value, factor), ptr)
+
+	case parser.CmdScan:
+		n := g.label()
+
+		g.branch("scan%d", n)
+		g.block("scan%d", n)
+		g.test(fmt.Sprintf("scan%d.step", n), fmt.Sprintf("scan%d.end", n))
+		g.block("scan%d.step", n)
+		g.move(ins.Arg)
+		g.branch("scan%d", n)
+		g.block("scan%d.end", n)
+
+	case parser.CmdJump:
+		n := g.label()
+		g.loops = append(g.loops, n)
+
+		g.branch("loop%d", n)
+		g.block("loop%d", n)
+		g.test(fmt.Sprintf("loop%d.body", n), fmt.Sprintf("loop%d.end", n))
+		g.block("loop%d.body", n)
+
+	case parser.CmdReturn:
+		n := g.loops[len(g.loops)-1]
+		g.loops = g.loops[:len(g.loops)-1]
+
+		g.branch("loop%d", n)
+		g.block("loop%d.end", n)
+
+	case parser.CmdInput:
+		g.input()
+
+	case parser.CmdOutput:
+		value := g.load(g.address(0))
+		g.printf("  call i32 @putchar(i32 %s)\n", g.resize(value, g.opts.CellSize, 32))
+	}
+}
+
+// op writes an instruction into a new temporary, and returns its name
+func (g *generator) op(format string, args ...interface{}) string {
+	g.temps++
+	name := fmt.Sprintf("%%t%d", g.temps)
+	g.printf("  %s = %s\n", name, fmt.Sprintf(format, args...))
+	return name
+}
+
+func (g *generator) label() int {
+	g.labels++
+	return g.labels
+}
+
+func (g *generator) block(format string, n int) {
+	g.printf(format+":\n", n)
+}
+
+func (g *generator) branch(format string, n int) {
+	g.printf("  br label %%"+format+"\n", n)
+}
+
+// test branches to nonzero or zero, depending on the current cell
+func (g *generator) test(nonzero string, zero string) {
+	cond := g.op("icmp ne %s %s, 0", g.cell, g.load(g.address(0)))
+	g.printf("  br i1 %s, label %%%s, label %%%s\n", cond, nonzero, zero)
+}
+
+// address returns the pointer to the cell at offset from the current one
+func (g *generator) address(offset int) string {
+	index := g.op("load i64, ptr %%p")
+
+	if offset = codegen.Wrap(offset, g.opts.TapeSize); offset != 0 {
+		sum := g.op("add i64 %s, %d", index, offset)
+		index = g.op("urem i64 %s, %d", sum, g.opts.TapeSize)
+	}
+
+	return g.op("getelementptr inbounds %s, ptr @tape, i64 0, i64 %s", g.tapeType, index)
+}
+
+// move moves the pointer, wrapping around the ends of the tape
+func (g *generator) move(delta int) {
+	if delta = codegen.Wrap(delta, g.opts.TapeSize); delta == 0 {
+		return
+	}
+
+	sum := g.op("add i64 %s, %d", g.op("load i64, ptr %%p"), delta)
+	g.printf("  store i64 %s, ptr %%p\n", g.op("urem i64 %s, %d", sum, g.opts.TapeSize))
+}
+
+func (g *generator) load(ptr string) string {
+	return g.op("load %s, ptr %s", g.cell, ptr)
+}
+
+func (g *generator) store(value string, ptr string) {
+	g.printf("  store %s %s, ptr %s\n", g.cell, value, ptr)
+}
+
+// constant returns the cell literal of the value, as LLVM prints it: the
+// value truncated to the cell size, read as a signed number
+func (g *generator) constant(value int) string {
+	bits := uint(g.opts.CellSize)
+	return fmt.Sprint(int64(codegen.Truncate(value, g.opts.CellSize)<<(64-bits)) >> (64 - bits))
+}
+
+// resize converts an integer between sizes, in bits
+func (g *generator) resize(value string, from int, to int) string {
+	switch {
+	case from < to:
+		return g.op("zext i%d %s to i%d", from, value, to)
+	case from > to:
+		return g.op("trunc i%d %s to i%d", from, value, to)
+	default:
+		return value
+	}
+}
+
+func (g *generator) input() {
+	n := g.label()
+	char := g.op("call i32 @getchar()")
+	eof := g.op("icmp eq i32 %s, -1", char)
+
+	g.printf("  br i1 %s, label %%input%d.eof, label %%input%d.ok\n", eof, n, n)
+	g.block("input%d.ok", n)
+	g.store(g.resize(char, 32, g.opts.CellSize), g.address(0))
+	g.branch("input%d.end", n)
+	g.block("input%d.eof", n)
+
+	switch g.opts.EOFMode {
+	case vm.EOFZero:
+		g.store("0", g.address(0))
+	case vm.EOFMinusOne:
+		g.store("-1", g.address(0))
+	case vm.EOFError:
+		g.printf("  call i32 @fflush(ptr null)\n")
+		g.printf("  call i64 @write(i32 2, ptr @eof_message, i64 %d)\n", len(eofMessage))
+		g.printf("  call void @exit(i32 1)\n")
+		g.printf("  unreachable\n")
+		g.block("input%d.end", n)
+		return
+	}
+
+	g.branch("input%d.end", n)
+	g.block("input%d.end", n)
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g, format, args...)
+}
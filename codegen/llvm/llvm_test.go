@@ -0,0 +1,88 @@
+package llvm_test
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/codegen/codegentest"
+	"github.com/ibraimgm/bfi/codegen/llvm"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+func TestEmitCellSizes(t *testing.T) {
+	testCases := []struct {
+		cellSize int
+		expected []string
+	}{
+		{cellSize: 8, expected: []string{"[30 x i8] zeroinitializer", "add i8 %t3, -1", "trunc i32 %t5 to i8", "zext i8"}},
+		{cellSize: 16, expected: []string{"[30 x i16] zeroinitializer", "add i16 %t3, -1", "trunc i32 %t5 to i16", "zext i16"}},
+		{cellSize: 32, expected: []string{"[30 x i32] zeroinitializer", "add i32 %t3, -1", "store i32 %t5"}},
+		{cellSize: 64, expected: []string{"[30 x i64] zeroinitializer", "add i64 %t3, -1", "zext i32 %t5 to i64", "trunc i64"}},
+	}
+
+	program, err := codegentest.Program("-,.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, test := range testCases {
+		opts := llvm.Options{Options: codegen.Options{CellSize: test.cellSize, TapeSize: 30, EOFMode: vm.EOFZero}}
+
+		var buf bytes.Buffer
+		if err := llvm.Emit(&buf, program, opts); err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		for _, s := range test.expected {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("Case %v, expected \"%v\" in the generated code:\n%s", i, s, buf.String())
+			}
+		}
+	}
+}
+
+// compile runs llc on the module, retrying with opaque pointers enabled for
+// LLVM 14
+func compile(llc string, src string, obj string) error {
+	args := []string{"-relocation-model=pic", "-filetype=obj", "-o", obj, src}
+
+	err := codegentest.Exec(exec.Command(llc, args...))
+	if err != nil && codegentest.Exec(exec.Command(llc, append([]string{"-opaque-pointers"}, args...)...)) == nil {
+		return nil
+	}
+
+	return err
+}
+
+// TestEmitMatchesVM compiles the generated modules with llc, links them with
+// the local C compiler, and compares them with the virtual machine
+func TestEmitMatchesVM(t *testing.T) {
+	llc, err := exec.LookPath("llc")
+	if err != nil {
+		t.Skip("llc is not available")
+	}
+
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler available")
+	}
+
+	emit := func(w io.Writer, program []parser.Instruction, opts codegen.Options) error {
+		return llvm.Emit(w, program, llvm.Options{Options: opts})
+	}
+
+	codegentest.Run(t, ".ll", emit, func(_ codegentest.Case, src string) (*exec.Cmd, error) {
+		bin := strings.TrimSuffix(src, ".ll")
+
+		if err := compile(llc, src, bin+".o"); err != nil {
+			return nil, err
+		}
+
+		return exec.Command(bin), codegentest.Exec(exec.Command(cc, "-o", bin, bin+".o"))
+	})
+}
@@ -0,0 +1,104 @@
+package native
+
+import (
+	"encoding/binary"
+)
+
+// symbol is an address only known when the executable is laid out
+type symbol int
+
+const (
+	symTape symbol = iota
+	symMessage
+)
+
+// reloc is the position in the code of a 64-bit address
+type reloc struct {
+	at  int
+	sym symbol
+}
+
+// layout of the executable: the headers, the code and the message share a
+// read-only, executable segment, followed by a writable segment with the
+// scratch byte of the input and the tape, that takes no space in the file
+const (
+	baseAddr   = 0x400000
+	pageSize   = 0x1000
+	headerSize = 64
+	phdrSize   = 56
+	phdrCount  = 3
+	codeOffset = headerSize + phdrSize*phdrCount
+
+	// the tape starts after the scratch bytes
+	tapeOffset = 8
+)
+
+// ELF constants used by the headers
+const (
+	elfClass64   = 2
+	elfData2LSB  = 1
+	elfVersion   = 1
+	elfOSABINone = 0
+	elfTypeExec  = 2
+	elfMachine   = 62 // EM_X86_64
+
+	ptLoad     = 1
+	ptGNUStack = 0x6474E551
+	pfX        = 1
+	pfW        = 2
+	pfR        = 4
+)
+
+// link lays out the executable, resolving the addresses used by the code
+func link(code []byte, relocs []reloc, tapeBytes int64) []byte {
+	textSize := uint64(codeOffset + len(code) + len(eofMessage))
+	bssAddr := (baseAddr + textSize + pageSize - 1) &^ (pageSize - 1)
+
+	addrs := map[symbol]uint64{
+		symTape:    bssAddr + tapeOffset,
+		symMessage: baseAddr + codeOffset + uint64(len(code)),
+	}
+
+	for _, r := range relocs {
+		binary.LittleEndian.PutUint64(code[r.at:], addrs[r.sym])
+	}
+
+	buf := make([]byte, codeOffset, textSize)
+	le := binary.LittleEndian
+
+	// ELF header
+	copy(buf, []byte{0x7F, 'E', 'L', 'F', elfClass64, elfData2LSB, elfVersion, elfOSABINone})
+	le.PutUint16(buf[16:], elfTypeExec)
+	le.PutUint16(buf[18:], elfMachine)
+	le.PutUint32(buf[20:], elfVersion)
+	le.PutUint64(buf[24:], baseAddr+codeOffset) // entry point
+	le.PutUint64(buf[32:], headerSize)          // program headers
+	le.PutUint64(buf[40:], 0)                   // no section headers
+	le.PutUint32(buf[48:], 0)                   // flags
+	le.PutUint16(buf[52:], headerSize)
+	le.PutUint16(buf[54:], phdrSize)
+	le.PutUint16(buf[56:], phdrCount)
+	le.PutUint16(buf[58:], 0) // section header size
+	le.PutUint16(buf[60:], 0) // section header count
+	le.PutUint16(buf[62:], 0) // section name table
+
+	phdr := func(i int, typ uint32, flags uint32, offset uint64, addr uint64, fileSize uint64, memSize uint64, align uint64) {
+		p := buf[headerSize+i*phdrSize:]
+		le.PutUint32(p[0:], typ)
+		le.PutUint32(p[4:], flags)
+		le.PutUint64(p[8:], offset)
+		le.PutUint64(p[16:], addr)
+		le.PutUint64(p[24:], addr)
+		le.PutUint64(p[32:], fileSize)
+		le.PutUint64(p[40:], memSize)
+		le.PutUint64(p[48:], align)
+	}
+
+	phdr(0, ptLoad, pfR|pfX, 0, baseAddr, textSize, textSize, pageSize)
+	phdr(1, ptLoad, pfR|pfW, 0, bssAddr, 0, uint64(tapeOffset+tapeBytes), pageSize)
+	phdr(2, ptGNUStack, pfR|pfW, 0, 0, 0, 0, 16)
+
+	buf = append(buf, code...)
+	buf = append(buf, eofMessage...)
+	return buf
+}
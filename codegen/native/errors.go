@@ -0,0 +1,13 @@
+package native
+
+import (
+	"fmt"
+)
+
+// TapeTooLargeError indicates that the tape can not be addressed by the
+// generated code.
+type TapeTooLargeError int
+
+func (err TapeTooLargeError) Error() string {
+	return fmt.Sprintf("tape too large for a native executable: %v cells", int(err))
+}
@@ -0,0 +1,206 @@
+// Package native translates brainf*ck programs into static linux/amd64
+// executables, with no external assembler or linker.
+//
+// The executable is made of the machine code, followed by a message for
+// running out of input, and a tape in uninitialized memory. Input and output
+// are made directly with the read and write system calls, one byte at a
+// time, and the output is never buffered.
+package native
+
+import (
+	"io"
+	"math"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/codegen/amd64"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// Options controls the generated executable
+type Options struct {
+	codegen.Options
+}
+
+// registers used by the generated code. RAX, RCX, RDX, RSI, RDI, R9 and R11
+// are scratch registers, and the system calls may change RCX and R11.
+const (
+	regTape = amd64.RBX // address of the first cell
+	regPos  = amd64.R12 // index of the current cell
+	regSize = amd64.R13 // number of cells in the tape
+)
+
+// Linux system call numbers and file descriptors
+const (
+	sysRead      = 0
+	sysWrite     = 1
+	sysExitGroup = 231
+
+	stdin  = 0
+	stdout = 1
+	stderr = 2
+)
+
+// eofMessage is written to the standard error when the program runs out of
+// input, with vm.EOFError
+const eofMessage = "ran out of input\n"
+
+type generator struct {
+	amd64.Assembler
+	opts   Options
+	tape   amd64.Tape
+	loops  []amd64.Label
+	relocs []reloc
+
+	// eof is the label of the code that handles vm.EOFError
+	eof amd64.Label
+}
+
+// Emit writes the program as an ELF executable
+func Emit(w io.Writer, program []parser.Instruction, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	width := opts.CellSize / 8
+
+	// every offset in the tape must fit in a 32-bit displacement
+	if int64(opts.TapeSize)*int64(width) > math.MaxInt32 {
+		return TapeTooLargeError(opts.TapeSize)
+	}
+
+	// moves around the whole tape would still be computed
+	program = opts.Prune(program)
+
+	tape := amd64.Tape{Base: regTape, Pos: regPos, Size: regSize, Width: width}
+	g := &generator{opts: opts, tape: tape, eof: -1}
+	g.start()
+
+	for _, ins := range program {
+		g.instruction(ins)
+	}
+
+	g.exit(0)
+
+	if g.eof >= 0 {
+		g.eofError()
+	}
+
+	_, err := w.Write(link(g.Bytes(), g.relocs, int64(opts.TapeSize)*int64(width)))
+	return err
+}
+
+// movAddr loads the address of the symbol into r, once it is known
+func (g *generator) movAddr(r amd64.Reg, sym symbol) {
+	g.MovRegImm64(r, 0)
+	g.relocs = append(g.relocs, reloc{at: g.Len() - 8, sym: sym})
+}
+
+func (g *generator) start() {
+	g.movAddr(regTape, symTape)
+	g.MovRegImm64(regSize, uint64(g.opts.TapeSize))
+	g.MovRegImm32(regPos, 0)
+}
+
+// scratch returns the memory operand of the byte before the tape, used as
+// the buffer of the read system call
+func scratch() amd64.Mem {
+	return amd64.Mem{Base: regTape, Index: amd64.NoReg, Disp: -8}
+}
+
+// locate computes the index of the cell at delta from the current one into
+// r, wrapping around the end of the tape
+func (g *generator) locate(r amd64.Reg, delta int) bool {
+	skip := g.NewLabel()
+
+	g.Lea(r, amd64.Mem{Base: regPos, Index: amd64.NoReg, Disp: int32(codegen.Wrap(delta, g.opts.TapeSize))})
+	g.CmpRegReg(r, regSize)
+	g.Jcc(amd64.CondB, skip)
+	g.SubRegReg(r, regSize)
+	g.Bind(skip)
+	return true
+}
+
+func (g *generator) instruction(ins parser.Instruction) {
+	switch ins.Cmd {
+	case parser.CmdJump:
+		body, done := g.NewLabel(), g.NewLabel()
+
+		g.CmpCellZero(g.tape.Width, g.tape.Cell(regPos))
+		g.Jcc(amd64.CondE, done)
+		g.Bind(body)
+		g.loops = append(g.loops, body, done)
+
+	case parser.CmdReturn:
+		body, done := g.loops[len(g.loops)-2], g.loops[len(g.loops)-1]
+		g.loops = g.loops[:len(g.loops)-2]
+
+		g.CmpCellZero(g.tape.Width, g.tape.Cell(regPos))
+		g.Jcc(amd64.CondNE, body)
+		g.Bind(done)
+
+	case parser.CmdInput:
+		g.input()
+
+	case parser.CmdOutput:
+		// the low byte of a cell is its first one
+		g.Lea(amd64.RSI, g.tape.Cell(regPos))
+		g.syscall(sysWrite, stdout, 1)
+
+	default:
+		g.Lower(g.tape, ins, g.locate)
+	}
+}
+
+// syscall makes a system call with the buffer already in RSI
+func (g *generator) syscall(number uint32, fd uint32, count uint32) {
+	g.MovRegImm32(amd64.RAX, number)
+	g.MovRegImm32(amd64.RDI, fd)
+	g.MovRegImm32(amd64.RDX, count)
+	g.Syscall()
+}
+
+// input reads a byte into the current cell. Errors are handled as the end
+// of the input, as getchar does.
+func (g *generator) input() {
+	eof, done := g.NewLabel(), g.NewLabel()
+
+	g.Lea(amd64.RSI, scratch())
+	g.syscall(sysRead, stdin, 1)
+	g.CmpRegImm32(amd64.RAX, 1)
+	g.Jcc(amd64.CondNE, eof)
+	g.LoadCell(1, amd64.RAX, scratch())
+	g.StoreCell(g.tape.Width, g.tape.Cell(regPos), amd64.RAX)
+	g.Jmp(done)
+
+	g.Bind(eof)
+
+	switch g.opts.EOFMode {
+	case vm.EOFZero:
+		g.MovCellImm(g.tape.Width, g.tape.Cell(regPos), 0)
+	case vm.EOFMinusOne:
+		g.MovCellImm(g.tape.Width, g.tape.Cell(regPos), -1)
+	case vm.EOFError:
+		if g.eof < 0 {
+			g.eof = g.NewLabel()
+		}
+
+		g.Jmp(g.eof)
+	}
+
+	g.Bind(done)
+}
+
+// eofError writes the message and exits with status 1
+func (g *generator) eofError() {
+	g.Bind(g.eof)
+	g.movAddr(amd64.RSI, symMessage)
+	g.syscall(sysWrite, stderr, uint32(len(eofMessage)))
+	g.exit(1)
+}
+
+func (g *generator) exit(status uint32) {
+	g.MovRegImm32(amd64.RAX, sysExitGroup)
+	g.MovRegImm32(amd64.RDI, status)
+	g.Syscall()
+}
@@ -0,0 +1,104 @@
+package native_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/codegen/codegentest"
+	"github.com/ibraimgm/bfi/codegen/native"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+func TestHeaders(t *testing.T) {
+	testCases := []struct {
+		cellSize int
+		tapeSize int
+		bssSize  uint64
+	}{
+		{cellSize: 8, tapeSize: 3000, bssSize: 3008},
+		{cellSize: 64, tapeSize: 100000, bssSize: 800008},
+	}
+
+	program, err := codegentest.Program(",[.,]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, test := range testCases {
+		opts := native.Options{Options: codegen.Options{CellSize: test.cellSize, TapeSize: test.tapeSize, EOFMode: vm.EOFError}}
+
+		var buf bytes.Buffer
+		if err := native.Emit(&buf, program, opts); err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		f, err := elf.NewFile(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		if f.Class != elf.ELFCLASS64 || f.Machine != elf.EM_X86_64 || f.Type != elf.ET_EXEC {
+			t.Errorf("Case %v, expected a static amd64 executable, but got %v %v %v", i, f.Class, f.Machine, f.Type)
+		}
+
+		var loads []*elf.Prog
+		for _, p := range f.Progs {
+			if p.Type == elf.PT_LOAD {
+				loads = append(loads, p)
+			}
+		}
+
+		if len(loads) != 2 {
+			t.Fatalf("Case %v, expected 2 loadable segments, but got %v", i, len(loads))
+		}
+
+		text, bss := loads[0], loads[1]
+
+		if text.Flags != elf.PF_R|elf.PF_X || f.Entry < text.Vaddr || f.Entry >= text.Vaddr+text.Memsz {
+			t.Errorf("Case %v, expected the entry point in the executable segment", i)
+		}
+
+		if text.Filesz != uint64(buf.Len()) {
+			t.Errorf("Case %v, expected the code segment to cover the whole file", i)
+		}
+
+		if bss.Flags != elf.PF_R|elf.PF_W || bss.Filesz != 0 || bss.Memsz != test.bssSize {
+			t.Errorf("Case %v, expected a writable segment of %v bytes, but got %v (%v in the file)", i, test.bssSize, bss.Memsz, bss.Filesz)
+		}
+
+		if bss.Vaddr < text.Vaddr+text.Memsz || bss.Vaddr%0x1000 != 0 {
+			t.Errorf("Case %v, segments overlap or are misaligned", i)
+		}
+	}
+}
+
+func TestTapeTooLarge(t *testing.T) {
+	opts := native.Options{Options: codegen.Options{CellSize: 64, TapeSize: 1 << 28, EOFMode: vm.EOFError}}
+
+	if err := native.Emit(&bytes.Buffer{}, nil, opts); err != native.TapeTooLargeError(1<<28) {
+		t.Errorf("expected a TapeTooLargeError, but got %v", err)
+	}
+}
+
+// TestEmitMatchesVM runs the generated executables, and compares them with
+// the virtual machine
+func TestEmitMatchesVM(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("the executables only run on linux/amd64")
+	}
+
+	emit := func(w io.Writer, program []parser.Instruction, opts codegen.Options) error {
+		return native.Emit(w, program, native.Options{Options: opts})
+	}
+
+	codegentest.Run(t, "", emit, func(_ codegentest.Case, bin string) (*exec.Cmd, error) {
+		return exec.Command(bin), os.Chmod(bin, 0755)
+	})
+}
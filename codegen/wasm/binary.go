@@ -0,0 +1,209 @@
+package wasm
+
+import (
+	"bytes"
+)
+
+// section ids, in the order they must appear in the module
+const (
+	sectionType     = 1
+	sectionImport   = 2
+	sectionFunction = 3
+	sectionMemory   = 5
+	sectionExport   = 7
+	sectionCode     = 10
+)
+
+// value types and other encoding constants
+const (
+	typeI32   = 0x7F
+	typeFunc  = 0x60
+	blockVoid = 0x40
+
+	kindFunc   = 0x00
+	kindMemory = 0x02
+
+	limitsMinMax = 0x01
+)
+
+// Magic and Version are the first bytes of every binary module
+var (
+	Magic   = []byte{0x00, 'a', 's', 'm'}
+	Version = []byte{0x01, 0x00, 0x00, 0x00}
+)
+
+// encoder writes the binary encoding of the values used by a module
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) u32(v uint32) {
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+
+		if v == 0 {
+			e.WriteByte(b)
+			return
+		}
+
+		e.WriteByte(b | 0x80)
+	}
+}
+
+func (e *encoder) s64(v int64) {
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+
+		// stop when the remaining bits are only copies of the sign bit
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			e.WriteByte(b)
+			return
+		}
+
+		e.WriteByte(b | 0x80)
+	}
+}
+
+func (e *encoder) name(s string) {
+	e.u32(uint32(len(s)))
+	e.WriteString(s)
+}
+
+// vector writes the element count, followed by the elements
+func (e *encoder) vector(elems ...[]byte) {
+	e.u32(uint32(len(elems)))
+
+	for _, elem := range elems {
+		e.Write(elem)
+	}
+}
+
+// section writes the section id, followed by its size and contents
+func (e *encoder) section(id byte, contents []byte) {
+	e.WriteByte(id)
+	e.u32(uint32(len(contents)))
+	e.Write(contents)
+}
+
+// encode returns the bytes written by fn
+func encode(fn func(e *encoder)) []byte {
+	var e encoder
+	fn(&e)
+	return e.Bytes()
+}
+
+// alignment returns the log2 of the natural alignment of a memory access
+func alignment(op opcode) uint32 {
+	switch op {
+	case opI32Load8U, opI32Store8:
+		return 0
+	case opI32Load16U, opI32Store16:
+		return 1
+	case opI32Load, opI32Store:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func (e *encoder) instr(ins instr) {
+	e.WriteByte(byte(ins.op))
+
+	switch opcodes[ins.op].imm {
+	case immBlock:
+		e.WriteByte(blockVoid)
+	case immIndex:
+		e.u32(uint32(ins.arg))
+	case immI32:
+		e.s64(int64(int32(ins.arg)))
+	case immI64:
+		e.s64(ins.arg)
+	case immMem:
+		e.u32(alignment(ins.op))
+		e.u32(0)
+	}
+}
+
+// Binary returns the module in the binary format
+func (m *Module) Binary() []byte {
+	var e encoder
+
+	e.Write(Magic)
+	e.Write(Version)
+
+	e.section(sectionType, encode(func(e *encoder) {
+		e.vector(
+			[]byte{typeFunc, 0, 1, typeI32}, // read_byte
+			[]byte{typeFunc, 1, typeI32, 0}, // write_byte
+			[]byte{typeFunc, 0, 1, typeI32}, // run
+		)
+	}))
+
+	e.section(sectionImport, encode(func(e *encoder) {
+		e.vector(
+			importFunc("read_byte", funcReadByte),
+			importFunc("write_byte", funcWriteByte),
+		)
+	}))
+
+	e.section(sectionFunction, encode(func(e *encoder) {
+		// as with the imports, the type of run has the same index
+		e.vector([]byte{funcRun})
+	}))
+
+	e.section(sectionMemory, encode(func(e *encoder) {
+		e.vector(encode(func(e *encoder) {
+			e.WriteByte(limitsMinMax)
+			e.u32(uint32(m.Pages()))
+			e.u32(uint32(m.Pages()))
+		}))
+	}))
+
+	e.section(sectionExport, encode(func(e *encoder) {
+		e.vector(
+			export("run", kindFunc, funcRun),
+			export("memory", kindMemory, 0),
+		)
+	}))
+
+	e.section(sectionCode, encode(func(e *encoder) {
+		e.vector(encode(func(e *encoder) {
+			body := encode(func(e *encoder) {
+				// a single group of two i32 locals: the pointer and a temporary
+				e.vector([]byte{2, typeI32})
+
+				for _, ins := range m.body {
+					e.instr(ins)
+				}
+
+				e.WriteByte(byte(opEnd))
+			})
+
+			e.u32(uint32(len(body)))
+			e.Write(body)
+		}))
+	}))
+
+	return e.Bytes()
+}
+
+// importFunc returns the import entry of a function from the env module.
+// Function types have the same index as the function itself.
+func importFunc(name string, typeIndex uint32) []byte {
+	return encode(func(e *encoder) {
+		e.name("env")
+		e.name(name)
+		e.WriteByte(kindFunc)
+		e.u32(typeIndex)
+	})
+}
+
+func export(name string, kind byte, index uint32) []byte {
+	return encode(func(e *encoder) {
+		e.name(name)
+		e.WriteByte(kind)
+		e.u32(index)
+	})
+}
@@ -0,0 +1,13 @@
+package wasm
+
+import (
+	"fmt"
+)
+
+// TapeTooLargeError indicates that the tape does not fit in the memory of a
+// module.
+type TapeTooLargeError int
+
+func (err TapeTooLargeError) Error() string {
+	return fmt.Sprintf("tape too large for a WebAssembly module: %v cells", int(err))
+}
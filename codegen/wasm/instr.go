@@ -0,0 +1,103 @@
+package wasm
+
+// opcode is a WebAssembly instruction opcode
+type opcode byte
+
+// List of the instructions used by the code generator
+const (
+	opBlock      opcode = 0x02
+	opLoop       opcode = 0x03
+	opIf         opcode = 0x04
+	opElse       opcode = 0x05
+	opEnd        opcode = 0x0B
+	opBr         opcode = 0x0C
+	opBrIf       opcode = 0x0D
+	opReturn     opcode = 0x0F
+	opCall       opcode = 0x10
+	opLocalGet   opcode = 0x20
+	opLocalSet   opcode = 0x21
+	opLocalTee   opcode = 0x22
+	opI32Load    opcode = 0x28
+	opI64Load    opcode = 0x29
+	opI32Load8U  opcode = 0x2D
+	opI32Load16U opcode = 0x2F
+	opI32Store   opcode = 0x36
+	opI64Store   opcode = 0x37
+	opI32Store8  opcode = 0x3A
+	opI32Store16 opcode = 0x3B
+	opI32Const   opcode = 0x41
+	opI64Const   opcode = 0x42
+	opI32Eqz     opcode = 0x45
+	opI32LtS     opcode = 0x48
+	opI32GeU     opcode = 0x4F
+	opI64Eqz     opcode = 0x50
+	opI32Add     opcode = 0x6A
+	opI32Sub     opcode = 0x6B
+	opI32RemU    opcode = 0x70
+	opI64Add     opcode = 0x7C
+	opI64Mul     opcode = 0x7E
+	opI32Mul     opcode = 0x6C
+	opI32WrapI64 opcode = 0xA7
+	opI64ExtendU opcode = 0xAD
+)
+
+// immediate is the kind of operand that follows an opcode
+type immediate int
+
+const (
+	immNone immediate = iota
+	immBlock
+	immIndex
+	immI32
+	immI64
+	immMem
+)
+
+type opInfo struct {
+	name string
+	imm  immediate
+}
+
+var opcodes = map[opcode]opInfo{
+	opBlock:      {"block", immBlock},
+	opLoop:       {"loop", immBlock},
+	opIf:         {"if", immBlock},
+	opElse:       {"else", immNone},
+	opEnd:        {"end", immNone},
+	opBr:         {"br", immIndex},
+	opBrIf:       {"br_if", immIndex},
+	opReturn:     {"return", immNone},
+	opCall:       {"call", immIndex},
+	opLocalGet:   {"local.get", immIndex},
+	opLocalSet:   {"local.set", immIndex},
+	opLocalTee:   {"local.tee", immIndex},
+	opI32Load:    {"i32.load", immMem},
+	opI64Load:    {"i64.load", immMem},
+	opI32Load8U:  {"i32.load8_u", immMem},
+	opI32Load16U: {"i32.load16_u", immMem},
+	opI32Store:   {"i32.store", immMem},
+	opI64Store:   {"i64.store", immMem},
+	opI32Store8:  {"i32.store8", immMem},
+	opI32Store16: {"i32.store16", immMem},
+	opI32Const:   {"i32.const", immI32},
+	opI64Const:   {"i64.const", immI64},
+	opI32Eqz:     {"i32.eqz", immNone},
+	opI32LtS:     {"i32.lt_s", immNone},
+	opI32GeU:     {"i32.ge_u", immNone},
+	opI64Eqz:     {"i64.eqz", immNone},
+	opI32Add:     {"i32.add", immNone},
+	opI32Sub:     {"i32.sub", immNone},
+	opI32RemU:    {"i32.rem_u", immNone},
+	opI64Add:     {"i64.add", immNone},
+	opI64Mul:     {"i64.mul", immNone},
+	opI32Mul:     {"i32.mul", immNone},
+	opI32WrapI64: {"i32.wrap_i64", immNone},
+	opI64ExtendU: {"i64.extend_i32_u", immNone},
+}
+
+// instr is a single instruction, with its immediate operand. Memory
+// instructions always use the natural alignment and no offset.
+type instr struct {
+	op  opcode
+	arg int64
+}
@@ -0,0 +1,74 @@
+package wasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ibraimgm/bfi/codegen"
+)
+
+// Text returns the module in the text format
+func (m *Module) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, ";; %s\n", codegen.Header(m.opts.Source))
+	b.WriteString("(module\n")
+	b.WriteString("  (import \"env\" \"read_byte\" (func $read_byte (result i32)))\n")
+	b.WriteString("  (import \"env\" \"write_byte\" (func $write_byte (param i32)))\n")
+	fmt.Fprintf(&b, "  (memory (export \"memory\") %d %d)\n", m.Pages(), m.Pages())
+	b.WriteString("  (func (export \"run\") (result i32)\n")
+	b.WriteString("    (local $p i32) (local $t i32)\n")
+
+	indent := 2
+
+	for _, ins := range m.body {
+		if ins.op == opEnd || ins.op == opElse {
+			indent--
+		}
+
+		b.WriteString(strings.Repeat("  ", indent))
+		b.WriteString(text(ins))
+		b.WriteByte('\n')
+
+		if opcodes[ins.op].imm == immBlock || ins.op == opElse {
+			indent++
+		}
+	}
+
+	b.WriteString("  )\n")
+	b.WriteString(")\n")
+	return b.String()
+}
+
+// text returns the instruction in the text format
+func text(ins instr) string {
+	info := opcodes[ins.op]
+
+	switch info.imm {
+	case immIndex:
+		return fmt.Sprintf("%s %s", info.name, indexName(ins))
+	case immI32:
+		return fmt.Sprintf("%s %d", info.name, int32(ins.arg))
+	case immI64:
+		return fmt.Sprintf("%s %d", info.name, ins.arg)
+	default:
+		return info.name
+	}
+}
+
+// indexName returns the name of a function or local, or the depth of a
+// branch target
+func indexName(ins instr) string {
+	switch {
+	case ins.op == opCall && ins.arg == funcReadByte:
+		return "$read_byte"
+	case ins.op == opCall && ins.arg == funcWriteByte:
+		return "$write_byte"
+	case ins.op == opBr || ins.op == opBrIf:
+		return fmt.Sprint(ins.arg)
+	case ins.arg == localPos:
+		return "$p"
+	default:
+		return "$t"
+	}
+}
@@ -0,0 +1,303 @@
+// Package wasm translates brainf*ck programs into WebAssembly modules, in
+// binary or text form, with no external toolchain.
+//
+// The module imports two functions from the "env" module, that perform the
+// input and output:
+//
+//	read_byte: func() -> i32   (the byte read, or -1 at the end of the input)
+//	write_byte: func(i32)      (writes the low 8 bits)
+//
+// It exports its memory, that holds the tape from address 0, and the
+// function that runs the program:
+//
+//	run: func() -> i32
+//
+// run returns 0 when the program finishes, or 1 when it runs out of input
+// and the EOF mode is vm.EOFError.
+package wasm
+
+import (
+	"io"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// PageSize is the size of a WebAssembly memory page
+const PageSize = 65536
+
+// MaxTapeBytes is the largest tape supported, in bytes. Addresses are
+// computed with 32-bit arithmetic, so a cell address plus an offset must not
+// overflow.
+const MaxTapeBytes = 1 << 31
+
+// Options controls the generated module
+type Options struct {
+	codegen.Options
+}
+
+// indexes of the functions and locals used by the generated code
+const (
+	funcReadByte  = 0
+	funcWriteByte = 1
+	funcRun       = 2
+
+	localPos  = 0 // address of the current cell
+	localTemp = 1
+)
+
+// Module is a WebAssembly module generated from a brainf*ck program
+type Module struct {
+	opts  Options
+	width int32
+	body  []instr
+}
+
+// Compile generates the module for the program
+func Compile(program []parser.Instruction, opts Options) (*Module, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	m := &Module{opts: opts, width: int32(opts.CellSize / 8)}
+
+	if m.tapeBytes() > MaxTapeBytes {
+		return nil, TapeTooLargeError(opts.TapeSize)
+	}
+
+	for _, ins := range program {
+		m.instruction(ins)
+	}
+
+	m.emit(opI32Const, 0)
+	return m, nil
+}
+
+// Emit writes the program as a binary WebAssembly module
+func Emit(w io.Writer, program []parser.Instruction, opts Options) error {
+	m, err := Compile(program, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(m.Binary())
+	return err
+}
+
+// EmitText writes the program as a WebAssembly module in text form
+func EmitText(w io.Writer, program []parser.Instruction, opts Options) error {
+	m, err := Compile(program, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, m.Text())
+	return err
+}
+
+// Pages returns the number of memory pages needed by the tape
+func (m *Module) Pages() int {
+	return int((m.tapeBytes() + PageSize - 1) / PageSize)
+}
+
+func (m *Module) emit(op opcode, arg int64) {
+	m.body = append(m.body, instr{op: op, arg: arg})
+}
+
+func (m *Module) ops(ops ...opcode) {
+	for _, op := range ops {
+		m.emit(op, 0)
+	}
+}
+
+// tapeBytes is the size of the tape, in bytes
+func (m *Module) tapeBytes() int64 {
+	return int64(m.opts.TapeSize) * int64(m.width)
+}
+
+func (m *Module) load() {
+	switch m.width {
+	case 1:
+		m.emit(opI32Load8U, 0)
+	case 2:
+		m.emit(opI32Load16U, 0)
+	case 4:
+		m.emit(opI32Load, 0)
+	default:
+		m.emit(opI64Load, 0)
+	}
+}
+
+func (m *Module) store() {
+	switch m.width {
+	case 1:
+		m.emit(opI32Store8, 0)
+	case 2:
+		m.emit(opI32Store16, 0)
+	case 4:
+		m.emit(opI32Store, 0)
+	default:
+		m.emit(opI64Store, 0)
+	}
+}
+
+// constant pushes a cell value. Values stored in cells smaller than 32 bits
+// are truncated by the store instruction.
+func (m *Module) constant(value int) {
+	if m.width == 8 {
+		m.emit(opI64Const, int64(value))
+	} else {
+		m.emit(opI32Const, int64(int32(codegen.Truncate(value, 32))))
+	}
+}
+
+// isZero pushes 1 if the current cell is zero
+func (m *Module) isZero() {
+	m.emit(opLocalGet, localPos)
+	m.load()
+
+	if m.width == 8 {
+		m.emit(opI64Eqz, 0)
+	} else {
+		m.emit(opI32Eqz, 0)
+	}
+}
+
+// address pushes the address of the cell at offset from the pointer, and
+// keeps it in the temporary local
+func (m *Module) address(offset int) {
+	offset = codegen.Wrap(offset, m.opts.TapeSize)
+	m.emit(opLocalGet, localPos)
+
+	if offset != 0 {
+		m.emit(opI32Const, int64(offset)*int64(m.width))
+		m.emit(opI32Add, 0)
+		m.emit(opI32Const, m.tapeBytes())
+		m.emit(opI32RemU, 0)
+	}
+
+	m.emit(opLocalTee, localTemp)
+}
+
+// move moves the pointer, wrapping around the ends of the tape
+func (m *Module) move(delta int) {
+	delta = codegen.Wrap(delta, m.opts.TapeSize)
+	if delta == 0 {
+		return
+	}
+
+	m.emit(opLocalGet, localPos)
+	m.emit(opI32Const, int64(delta)*int64(m.width))
+	m.emit(opI32Add, 0)
+	m.emit(opLocalTee, localPos)
+	m.emit(opI32Const, m.tapeBytes())
+	m.emit(opI32GeU, 0)
+	m.emit(opIf, 0)
+	m.emit(opLocalGet, localPos)
+	m.emit(opI32Const, m.tapeBytes())
+	m.emit(opI32Sub, 0)
+	m.emit(opLocalSet, localPos)
+	m.emit(opEnd, 0)
+}
+
+func (m *Module) instruction(ins parser.Instruction) {
+	add, mul := opI32Add, opI32Mul
+	if m.width == 8 {
+		add, mul = opI64Add, opI64Mul
+	}
+
+	switch ins.Cmd {
+	case parser.CmdMove:
+		m.move(ins.Arg)
+
+	case parser.CmdAdd:
+		m.address(ins.Offset)
+		m.emit(opLocalGet, localTemp)
+		m.load()
+		m.constant(ins.Arg)
+		m.ops(add)
+		m.store()
+
+	case parser.CmdClear:
+		m.address(ins.Offset)
+		m.constant(0)
+		m.store()
+
+	case parser.CmdMulAdd:
+		m.address(ins.Offset)
+		m.emit(opLocalGet, localTemp)
+		m.load()
+		m.emit(opLocalGet, localPos)
+		m.load()
+		m.constant(ins.Arg)
+		m.ops(mul, add)
+		m.store()
+
+	case parser.CmdScan:
+		m.ops(opBlock, opLoop)
+		m.isZero()
+		m.emit(opBrIf, 1)
+		m.move(ins.Arg)
+		m.emit(opBr, 0)
+		m.ops(opEnd, opEnd)
+
+	case parser.CmdJump:
+		m.ops(opBlock, opLoop)
+		m.isZero()
+		m.emit(opBrIf, 1)
+
+	case parser.CmdReturn:
+		m.emit(opBr, 0)
+		m.ops(opEnd, opEnd)
+
+	case parser.CmdInput:
+		m.input()
+
+	case parser.CmdOutput:
+		m.emit(opLocalGet, localPos)
+		m.load()
+
+		if m.width == 8 {
+			m.emit(opI32WrapI64, 0)
+		}
+
+		m.emit(opCall, funcWriteByte)
+	}
+}
+
+func (m *Module) input() {
+	m.emit(opCall, funcReadByte)
+	m.emit(opLocalTee, localTemp)
+	m.emit(opI32Const, 0)
+	m.emit(opI32LtS, 0)
+	m.emit(opIf, 0)
+
+	switch m.opts.EOFMode {
+	case vm.EOFZero, vm.EOFMinusOne:
+		m.emit(opLocalGet, localPos)
+
+		if m.opts.EOFMode == vm.EOFZero {
+			m.constant(0)
+		} else {
+			m.constant(-1)
+		}
+
+		m.store()
+
+	case vm.EOFError:
+		m.emit(opI32Const, 1)
+		m.emit(opReturn, 0)
+	}
+
+	m.emit(opElse, 0)
+	m.emit(opLocalGet, localPos)
+	m.emit(opLocalGet, localTemp)
+
+	if m.width == 8 {
+		m.emit(opI64ExtendU, 0)
+	}
+
+	m.store()
+	m.emit(opEnd, 0)
+}
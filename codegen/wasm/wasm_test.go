@@ -0,0 +1,249 @@
+package wasm_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/codegen/codegentest"
+	"github.com/ibraimgm/bfi/codegen/wasm"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// reader decodes the parts of a binary module checked by the tests
+type reader struct {
+	buf []byte
+	t   *testing.T
+}
+
+func (r *reader) byte() byte {
+	if len(r.buf) == 0 {
+		r.t.Fatal("unexpected end of module")
+	}
+
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *reader) u32() uint32 {
+	var v uint32
+
+	for shift := 0; ; shift += 7 {
+		b := r.byte()
+		v |= uint32(b&0x7F) << shift
+
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+}
+
+func (r *reader) bytes(n uint32) []byte {
+	if uint32(len(r.buf)) < n {
+		r.t.Fatal("unexpected end of module")
+	}
+
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) name() string {
+	return string(r.bytes(r.u32()))
+}
+
+// sections returns the contents of each section of the module, by id, and
+// the order they appear
+func sections(t *testing.T, module []byte) (map[byte]*reader, []byte) {
+	r := &reader{buf: module, t: t}
+
+	if !bytes.Equal(r.bytes(4), wasm.Magic) || !bytes.Equal(r.bytes(4), wasm.Version) {
+		t.Fatalf("invalid module header: % x", module[:8])
+	}
+
+	contents := make(map[byte]*reader)
+	var order []byte
+
+	for len(r.buf) > 0 {
+		id := r.byte()
+		contents[id] = &reader{buf: r.bytes(r.u32()), t: t}
+		order = append(order, id)
+	}
+
+	return contents, order
+}
+
+func TestBinaryStructure(t *testing.T) {
+	testCases := []struct {
+		cellSize int
+		tapeSize int
+		pages    uint32
+	}{
+		{cellSize: 8, tapeSize: 3000, pages: 1},
+		{cellSize: 16, tapeSize: 40000, pages: 2},
+		{cellSize: 64, tapeSize: 8192, pages: 1},
+		{cellSize: 64, tapeSize: 8193, pages: 2},
+	}
+
+	program, err := codegentest.Program("+[->,.<]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, test := range testCases {
+		opts := wasm.Options{Options: codegen.Options{CellSize: test.cellSize, TapeSize: test.tapeSize, EOFMode: vm.EOFError}}
+
+		var buf bytes.Buffer
+		if err := wasm.Emit(&buf, program, opts); err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		contents, order := sections(t, buf.Bytes())
+
+		if expected := []byte{1, 2, 3, 5, 7, 10}; !bytes.Equal(order, expected) {
+			t.Errorf("Case %v, expected sections %v, but got %v", i, expected, order)
+			continue
+		}
+
+		imports := contents[2]
+		var names []string
+
+		for n := imports.u32(); n > 0; n-- {
+			names = append(names, imports.name()+"."+imports.name())
+			imports.byte()
+			imports.u32()
+		}
+
+		if expected := []string{"env.read_byte", "env.write_byte"}; !reflect.DeepEqual(names, expected) {
+			t.Errorf("Case %v, expected imports %v, but got %v", i, expected, names)
+		}
+
+		memory := contents[5]
+		if count, flags := memory.u32(), memory.byte(); count != 1 || flags != 1 {
+			t.Errorf("Case %v, expected a single memory with a maximum", i)
+		}
+
+		if min, max := memory.u32(), memory.u32(); min != test.pages || max != test.pages {
+			t.Errorf("Case %v, expected %v pages, but got %v to %v", i, test.pages, min, max)
+		}
+
+		exports := contents[7]
+		names = nil
+
+		for n := exports.u32(); n > 0; n-- {
+			names = append(names, exports.name())
+			exports.byte()
+			exports.u32()
+		}
+
+		if expected := []string{"run", "memory"}; !reflect.DeepEqual(names, expected) {
+			t.Errorf("Case %v, expected exports %v, but got %v", i, expected, names)
+		}
+
+		code := contents[10]
+		if count := code.u32(); count != 1 {
+			t.Errorf("Case %v, expected 1 function body, but got %v", i, count)
+		}
+
+		body := code.bytes(code.u32())
+		if len(code.buf) != 0 || body[len(body)-1] != 0x0B {
+			t.Errorf("Case %v, malformed function body", i)
+		}
+	}
+}
+
+func TestTapeTooLarge(t *testing.T) {
+	opts := wasm.Options{Options: codegen.Options{CellSize: 64, TapeSize: 1 << 29, EOFMode: vm.EOFError}}
+
+	if _, err := wasm.Compile(nil, opts); err != wasm.TapeTooLargeError(1<<29) {
+		t.Errorf("expected a TapeTooLargeError, but got %v", err)
+	}
+}
+
+func TestText(t *testing.T) {
+	program, err := codegentest.Program(",[.,]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := wasm.Options{Options: codegen.DefaultOptions()}
+	opts.Source = "echo.bf"
+
+	var buf bytes.Buffer
+	if err := wasm.EmitText(&buf, program, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	text := buf.String()
+	expected := []string{
+		";; Code generated by bfi from echo.bf. DO NOT EDIT.",
+		`(import "env" "read_byte" (func $read_byte (result i32)))`,
+		`(memory (export "memory") 1 1)`,
+		`(func (export "run") (result i32)`,
+		"call $write_byte",
+		"i32.load8_u",
+		"br_if 1",
+	}
+
+	for i, s := range expected {
+		if !strings.Contains(text, s) {
+			t.Errorf("Case %v, expected \"%v\" in the generated module:\n%s", i, s, text)
+		}
+	}
+
+	if strings.Count(text, "(") != strings.Count(text, ")") {
+		t.Errorf("unbalanced parentheses in the generated module:\n%s", text)
+	}
+}
+
+// harness runs a module with node, reading its input from stdin and
+// writing its output to stdout
+const harness = `
+import { readFileSync, writeSync } from "node:fs";
+
+const input = readFileSync(0);
+const output = [];
+let pos = 0;
+
+const { instance } = await WebAssembly.instantiate(readFileSync(process.argv[2]), {
+  env: {
+    read_byte: () => (pos < input.length ? input[pos++] : -1),
+    write_byte: (b) => output.push(b & 0xff),
+  },
+});
+
+const status = instance.exports.run();
+writeSync(1, Uint8Array.from(output));
+process.exit(status);
+`
+
+// TestEmitMatchesVM runs the generated modules with node, and compares them
+// with the virtual machine
+func TestEmitMatchesVM(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not available")
+	}
+
+	script := filepath.Join(t.TempDir(), "harness.mjs")
+
+	if err := os.WriteFile(script, []byte(harness), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	emit := func(w io.Writer, program []parser.Instruction, opts codegen.Options) error {
+		return wasm.Emit(w, program, wasm.Options{Options: opts})
+	}
+
+	codegentest.Run(t, ".wasm", emit, func(_ codegentest.Case, module string) (*exec.Cmd, error) {
+		return exec.Command(node, script, module), nil
+	})
+}
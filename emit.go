@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/codegen/asm"
+	"github.com/ibraimgm/bfi/codegen/c"
+	"github.com/ibraimgm/bfi/codegen/golang"
+	"github.com/ibraimgm/bfi/codegen/js"
+	"github.com/ibraimgm/bfi/codegen/llvm"
+	"github.com/ibraimgm/bfi/codegen/wasm"
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+// emitOptions are the options of every code generator, as given in the
+// command line
+type emitOptions struct {
+	codegen.Options
+	Package string
+	Output  string
+	Wat     string
+}
+
+// emitters maps the languages accepted by emit to their code generators
+var emitters = map[string]func(w io.Writer, program []parser.Instruction, opts emitOptions) error{
+	"go": func(w io.Writer, program []parser.Instruction, opts emitOptions) error {
+		return golang.Emit(w, program, golang.Options{Options: opts.Options, Package: opts.Package})
+	},
+	"c": func(w io.Writer, program []parser.Instruction, opts emitOptions) error {
+		return c.Emit(w, program, c.Options{Options: opts.Options})
+	},
+	"wasm": emitWasm,
+	"js": func(w io.Writer, program []parser.Instruction, opts emitOptions) error {
+		return js.Emit(w, program, js.Options{Options: opts.Options})
+	},
+	"llvm": func(w io.Writer, program []parser.Instruction, opts emitOptions) error {
+		return llvm.Emit(w, program, llvm.Options{Options: opts.Options})
+	},
+	"asm": func(w io.Writer, program []parser.Instruction, opts emitOptions) error {
+		return asm.Emit(w, program, asm.Options{Options: opts.Options})
+	},
+}
+
+// emit translates the source file to another language, writing the result
+// to the output file or to the standard output
+func emit(lang string, filename string, pipeline *optimizer.Pipeline, opts emitOptions) error {
+	emitter, ok := emitters[lang]
+	if !ok {
+		return fmt.Errorf("unknown language: %s", lang)
+	}
+
+	program, err := load(filename, pipeline)
+	if err != nil {
+		return err
+	}
+
+	opts.Source = filepath.Base(filename)
+
+	if opts.Output == "" {
+		return emitter(os.Stdout, program, opts)
+	}
+
+	out, err := os.Create(opts.Output)
+	if err != nil {
+		return err
+	}
+
+	if err := emitter(out, program, opts); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// load reads and optimizes the source file for a code generator
+func load(filename string, pipeline *optimizer.Pipeline) ([]parser.Instruction, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", filename, err)
+	}
+	defer file.Close()
+
+	program, err := codegen.Load(file, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error loading source file:\n%v", err)
+	}
+
+	return program, nil
+}
+
+// emitWasm writes the binary module, and also the text form when a file
+// for it was given
+func emitWasm(w io.Writer, program []parser.Instruction, opts emitOptions) error {
+	module, err := wasm.Compile(program, wasm.Options{Options: opts.Options})
+	if err != nil {
+		return err
+	}
+
+	if opts.Wat != "" {
+		if err := os.WriteFile(opts.Wat, []byte(module.Text()), 0644); err != nil {
+			return err
+		}
+	}
+
+	_, err = w.Write(module.Binary())
+	return err
+}
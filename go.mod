@@ -1,5 +1,5 @@
 module github.com/ibraimgm/bfi
 
-go 1.12
+go 1.18
 
 require github.com/pborman/getopt v0.0.0-20190409184431-ee0cd42419d3
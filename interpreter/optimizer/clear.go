@@ -0,0 +1,35 @@
+package optimizer
+
+import (
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+// ClearLoops replaces loops like [-], [+] and [+++] with a single CmdClear
+// instruction.
+//
+// Only loops that add an odd amount to the cell are replaced: odd numbers
+// are invertible modulo any power of two, so these loops always reach zero,
+// whatever the cell size is. A loop like [--] never ends when the cell holds
+// an odd value, and is kept as it is.
+func ClearLoops(program []parser.Instruction) []parser.Instruction {
+	result := make([]parser.Instruction, 0, len(program))
+
+	for i := 0; i < len(program); i++ {
+		if isClearLoop(program[i:]) {
+			result = append(result, parser.Instruction{Cmd: parser.CmdClear, Span: spanOf(program[i : i+3])})
+			i += 2
+			continue
+		}
+
+		result = append(result, program[i])
+	}
+
+	return result
+}
+
+func isClearLoop(program []parser.Instruction) bool {
+	return len(program) >= 3 &&
+		program[0].Cmd == parser.CmdJump &&
+		program[1].Cmd == parser.CmdAdd && program[1].Arg%2 != 0 &&
+		program[2].Cmd == parser.CmdReturn
+}
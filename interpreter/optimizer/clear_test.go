@@ -0,0 +1,65 @@
+package optimizer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+func TestClearLoops(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+	}{
+		{source: `+[-]`, expected: `add 1; clear`},
+		{source: `[+]>[---]`, expected: `clear; move 1; clear`},
+		{source: `[--]`, expected: `jump 0; add -2; return 0`},
+		{source: `[-[-]]`, expected: `jump 0; add -1; clear; return 0`},
+		{source: `[->+<]`, expected: `jump 0; add -1; move 1; add 1; move -1; return 0`},
+		{source: `[-].[+]`, expected: `clear; output; clear`},
+	}
+
+	for i, test := range testCases {
+		program := parse(t, test.source)
+		result := dump(optimizer.ClearLoops(program))
+
+		if result != test.expected {
+			t.Errorf("Case %v, received \"%v\", expected \"%v\"", i, result, test.expected)
+		}
+	}
+}
+
+func TestClearLoopsSpan(t *testing.T) {
+	program := optimizer.ClearLoops(parse(t, `+ [ - ] +`))
+	expected := "1:3-1:8"
+
+	if len(program) != 3 {
+		t.Fatalf("Expected 3 instructions, received \"%v\"", dump(program))
+	}
+
+	if program[1].Span.String() != expected {
+		t.Errorf("Wrong span. Received \"%v\", expected \"%v\"", program[1].Span, expected)
+	}
+}
+
+func parse(t *testing.T, source string) []parser.Instruction {
+	program, err := parser.Parse(strings.NewReader(source))
+
+	if err != nil {
+		t.Fatalf("Unexpected error parsing \"%v\": %v", source, err)
+	}
+
+	return program
+}
+
+func dump(program []parser.Instruction) string {
+	parts := make([]string, len(program))
+
+	for i, ins := range program {
+		parts[i] = ins.String()
+	}
+
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,27 @@
+package optimizer
+
+import (
+	"fmt"
+)
+
+// UnknownPassError indicates that a pass name does not exist.
+type UnknownPassError string
+
+func (err UnknownPassError) Error() string {
+	return fmt.Sprintf("unknown optimizer pass: %v", string(err))
+}
+
+// InvalidLevelError indicates that a wrong optimization level was specified.
+type InvalidLevelError int
+
+func (err InvalidLevelError) Error() string {
+	return fmt.Sprintf("invalid optimization level: %v", int(err))
+}
+
+// InvalidDumpError indicates that a dump specification is not in the
+// "before:pass" or "after:pass" format.
+type InvalidDumpError string
+
+func (err InvalidDumpError) Error() string {
+	return fmt.Sprintf("invalid dump specification: %v", string(err))
+}
@@ -0,0 +1,82 @@
+package optimizer
+
+import (
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+// MulLoops replaces balanced loops like [->+>++<<] with a sequence of CmdMulAdd
+// instructions, followed by a CmdClear.
+//
+// A loop is balanced when its body only moves the pointer and changes cell
+// values, returns the pointer to where it started and decrements the loop cell
+// by exactly one. Such a loop runs as many times as the value of the loop cell,
+// so each cell it touches receives the value of the loop cell multiplied by the
+// amount added on every iteration. This holds modulo any power of two, so the
+// result is the same for every cell size.
+func MulLoops(program []parser.Instruction) []parser.Instruction {
+	return mulLoops(program, true)
+}
+
+// mulLoops replaces the balanced loops of the program. When clears is false,
+// the loops that would be replaced by a lone CmdClear are kept as they are.
+func mulLoops(program []parser.Instruction, clears bool) []parser.Instruction {
+	result := make([]parser.Instruction, 0, len(program))
+
+	for i := 0; i < len(program); i++ {
+		if program[i].Cmd == parser.CmdJump {
+			if loop, size := mulLoop(program[i:]); size > 0 && (clears || len(loop) > 1) {
+				result = append(result, loop...)
+				i += size - 1
+				continue
+			}
+		}
+
+		result = append(result, program[i])
+	}
+
+	return result
+}
+
+// mulLoop checks if the program starts with a balanced loop. If it does, returns
+// the instructions that replace it and the number of instructions replaced.
+func mulLoop(program []parser.Instruction) ([]parser.Instruction, int) {
+	offset := 0
+	order := make([]int, 0)
+	deltas := make(map[int]int)
+
+	for i := 1; i < len(program); i++ {
+		switch program[i].Cmd {
+		case parser.CmdMove:
+			offset += program[i].Arg
+
+		case parser.CmdAdd:
+			if _, ok := deltas[offset]; !ok {
+				order = append(order, offset)
+			}
+
+			deltas[offset] += program[i].Arg
+
+		case parser.CmdReturn:
+			if offset != 0 || deltas[0] != -1 {
+				return nil, 0
+			}
+
+			span := spanOf(program[:i+1])
+			result := make([]parser.Instruction, 0, len(order))
+
+			for _, target := range order {
+				if target != 0 && deltas[target] != 0 {
+					result = append(result, parser.Instruction{Cmd: parser.CmdMulAdd, Arg: deltas[target], Offset: target, Span: span})
+				}
+			}
+
+			result = append(result, parser.Instruction{Cmd: parser.CmdClear, Span: span})
+			return result, i + 1
+
+		default:
+			return nil, 0
+		}
+	}
+
+	return nil, 0
+}
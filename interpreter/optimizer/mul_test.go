@@ -0,0 +1,35 @@
+package optimizer_test
+
+import (
+	"testing"
+
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+)
+
+func TestMulLoops(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+	}{
+		{source: `[->+<]`, expected: `muladd 1 @+1; clear`},
+		{source: `[->+>++<<]`, expected: `muladd 1 @+1; muladd 2 @+2; clear`},
+		{source: `[<<--->>-]`, expected: `muladd -3 @-2; clear`},
+		{source: `[>+<-+-]`, expected: `muladd 1 @+1; clear`},
+		{source: `[>+<+-]`, expected: `jump 0; move 1; add 1; move -1; add 1; add -1; return 0`},
+		{source: `[->+<>-<]`, expected: `clear`},
+		{source: `[->+<<]`, expected: `jump 0; add -1; move 1; add 1; move -2; return 0`},
+		{source: `[-->+<]`, expected: `jump 0; add -2; move 1; add 1; move -1; return 0`},
+		{source: `[->+<.]`, expected: `jump 0; add -1; move 1; add 1; move -1; output; return 0`},
+		{source: `[->[-]<]`, expected: `jump 0; add -1; move 1; clear; move -1; return 0`},
+		{source: `[[->+<]]`, expected: `jump 0; muladd 1 @+1; clear; return 0`},
+	}
+
+	for i, test := range testCases {
+		program := parse(t, test.source)
+		result := dump(optimizer.MulLoops(program))
+
+		if result != test.expected {
+			t.Errorf("Case %v, received \"%v\", expected \"%v\"", i, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package optimizer
+
+import (
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+// DeferMoves folds pointer movement into the Offset of the instructions that
+// follow it, so code like >+>+>+<<< becomes three additions with offsets 1, 2
+// and 3, and no movement at all.
+//
+// The pointer is only moved, once, before instructions that depend on its
+// position: loop boundaries, scans, multiplications and I/O. Pending movement
+// is also flushed at the end of the program, so the final pointer position
+// does not change.
+func DeferMoves(program []parser.Instruction) []parser.Instruction {
+	result := make([]parser.Instruction, 0, len(program))
+	pending := parser.Instruction{Cmd: parser.CmdMove}
+	hasPending := false
+
+	flush := func() {
+		if pending.Arg != 0 {
+			result = append(result, pending)
+		}
+
+		pending = parser.Instruction{Cmd: parser.CmdMove}
+		hasPending = false
+	}
+
+	for _, ins := range program {
+		switch ins.Cmd {
+		case parser.CmdMove:
+			if hasPending {
+				pending.Span.End = ins.Span.End
+			} else {
+				pending.Span = ins.Span
+				hasPending = true
+			}
+
+			pending.Arg += ins.Arg
+
+		case parser.CmdAdd, parser.CmdClear:
+			ins.Offset += pending.Arg
+			result = append(result, ins)
+
+		default:
+			flush()
+			result = append(result, ins)
+		}
+	}
+
+	flush()
+	return result
+}
@@ -0,0 +1,54 @@
+package optimizer_test
+
+import (
+	"testing"
+
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+)
+
+func TestDeferMoves(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+	}{
+		{source: `>+>+>+<<<`, expected: `add 1 @+1; add 1 @+2; add 1 @+3`},
+		{source: `>+>-<<.`, expected: `add 1 @+1; add -1 @+2; output`},
+		{source: `>>+.`, expected: `add 1 @+2; move 2; output`},
+		{source: `>+<<[>]`, expected: `add 1 @+1; move -1; jump 0; move 1; return 0`},
+		{source: `>[-]<`, expected: `move 1; jump 0; add -1; return 0; move -1`},
+		{source: `>+>>`, expected: `add 1 @+1; move 3`},
+		{source: `>>,<<`, expected: `move 2; input; move -2`},
+	}
+
+	for i, test := range testCases {
+		program := parse(t, test.source)
+		result := dump(optimizer.DeferMoves(program))
+
+		if result != test.expected {
+			t.Errorf("Case %v, received \"%v\", expected \"%v\"", i, result, test.expected)
+		}
+	}
+}
+
+func TestDeferMovesAfterClear(t *testing.T) {
+	program := optimizer.DeferMoves(optimizer.ClearLoops(parse(t, `>>[-]>+<<<`)))
+	expected := `clear @+2; add 1 @+3`
+
+	if result := dump(program); result != expected {
+		t.Errorf("Received \"%v\", expected \"%v\"", result, expected)
+	}
+}
+
+func TestDeferMovesSpan(t *testing.T) {
+	program := optimizer.DeferMoves(parse(t, `> + > > .`))
+	// the flushed move covers every move folded into it
+	expected := "1:1-1:8"
+
+	if len(program) != 3 {
+		t.Fatalf("Expected 3 instructions, received \"%v\"", dump(program))
+	}
+
+	if program[1].Span.String() != expected {
+		t.Errorf("Wrong span. Received \"%v\", expected \"%v\"", program[1].Span, expected)
+	}
+}
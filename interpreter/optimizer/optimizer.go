@@ -0,0 +1,244 @@
+// Package optimizer rewrites parsed brainf*ck programs into equivalent
+// programs that run faster in the virtual machine.
+//
+// The optimizations are organized as an ordered pipeline of named passes.
+// Each pass is enabled from a minimum optimization level, and can be
+// disabled individually, which makes it possible to find out which pass is
+// responsible for a miscompilation.
+package optimizer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+// MaxLevel is the highest optimization level, that enables every pass
+const MaxLevel = 3
+
+// Pass is a single optimization step of the pipeline. Wrapping is true
+// when the pass is only correct if the cell arithmetic wraps around, and
+// FoldsMoves when it is only correct if moving the pointer never fails.
+type Pass struct {
+	Name       string
+	Level      int
+	Wrapping   bool
+	FoldsMoves bool
+	Run        func([]parser.Instruction) []parser.Instruction
+}
+
+// passes lists every available pass, in the order they run
+var passes = []Pass{
+	{Name: "clear", Level: 1, Wrapping: true, Run: ClearLoops},
+	{Name: "muladd", Level: 2, Wrapping: true, FoldsMoves: true, Run: MulLoops},
+	{Name: "scan", Level: 1, Run: ScanLoops},
+	{Name: "offsets", Level: 3, FoldsMoves: true, Run: DeferMoves},
+}
+
+// Passes returns every available pass, in the order they run
+func Passes() []Pass {
+	tmp := make([]Pass, len(passes))
+	copy(tmp, passes)
+	return tmp
+}
+
+func findPass(name string) (int, error) {
+	for i, pass := range passes {
+		if pass.Name == name {
+			return i, nil
+		}
+	}
+
+	return 0, UnknownPassError(name)
+}
+
+// Pipeline is an ordered list of passes, that might be individually
+// enabled or disabled
+type Pipeline struct {
+	enabled []bool
+	before  []bool
+	after   []bool
+	dump    io.Writer
+}
+
+// New returns a pipeline with the passes of the specified optimization level
+// enabled. Level 0 disables every pass.
+func New(level int) (*Pipeline, error) {
+	if level < 0 || level > MaxLevel {
+		return nil, InvalidLevelError(level)
+	}
+
+	p := &Pipeline{
+		enabled: make([]bool, len(passes)),
+		before:  make([]bool, len(passes)),
+		after:   make([]bool, len(passes)),
+	}
+
+	for i, pass := range passes {
+		p.enabled[i] = pass.Level <= level
+	}
+
+	return p, nil
+}
+
+// Enable turns on the pass with the specified name
+func (p *Pipeline) Enable(name string) error {
+	return p.set(p.enabled, name, true)
+}
+
+// Disable turns off the pass with the specified name
+func (p *Pipeline) Disable(name string) error {
+	return p.set(p.enabled, name, false)
+}
+
+// Enabled returns the names of the enabled passes, in the order they run
+func (p *Pipeline) Enabled() []string {
+	names := make([]string, 0, len(passes))
+
+	for i, pass := range passes {
+		if p.enabled[i] {
+			names = append(names, pass.Name)
+		}
+	}
+
+	return names
+}
+
+// WithoutWrapping returns a copy of the pipeline, with the passes that rely on
+// wrapping cell arithmetic disabled
+func (p *Pipeline) WithoutWrapping() *Pipeline {
+	return p.without(func(pass Pass) bool { return pass.Wrapping })
+}
+
+// WithoutFoldedMoves returns a copy of the pipeline, with the passes that
+// remove or merge pointer moves disabled. A move that fails when the tape has
+// fixed ends must fail at the same place, at every optimization level.
+func (p *Pipeline) WithoutFoldedMoves() *Pipeline {
+	return p.without(func(pass Pass) bool { return pass.FoldsMoves })
+}
+
+// without returns a copy of the pipeline, with the matching passes disabled
+func (p *Pipeline) without(match func(Pass) bool) *Pipeline {
+	other := &Pipeline{
+		enabled: make([]bool, len(passes)),
+		before:  p.before,
+		after:   p.after,
+		dump:    p.dump,
+	}
+
+	for i, pass := range passes {
+		other.enabled[i] = p.enabled[i] && !match(pass)
+	}
+
+	return other
+}
+
+// SetDumpOutput sets the writer that receives the program dumps requested
+// with DumpBefore and DumpAfter
+func (p *Pipeline) SetDumpOutput(w io.Writer) {
+	p.dump = w
+}
+
+// DumpBefore requests the program to be dumped right before the specified pass.
+// The dump happens even when the pass is disabled.
+func (p *Pipeline) DumpBefore(name string) error {
+	return p.set(p.before, name, true)
+}
+
+// DumpAfter requests the program to be dumped right after the specified pass.
+// The dump happens even when the pass is disabled.
+func (p *Pipeline) DumpAfter(name string) error {
+	return p.set(p.after, name, true)
+}
+
+// DumpAt requests a program dump using a "before:pass" or "after:pass"
+// specification, as used by the command line.
+func (p *Pipeline) DumpAt(spec string) error {
+	parts := strings.SplitN(spec, ":", 2)
+
+	if len(parts) == 2 {
+		switch parts[0] {
+		case "before":
+			return p.DumpBefore(parts[1])
+		case "after":
+			return p.DumpAfter(parts[1])
+		}
+	}
+
+	return InvalidDumpError(spec)
+}
+
+func (p *Pipeline) set(flags []bool, name string, value bool) error {
+	i, err := findPass(name)
+	if err != nil {
+		return err
+	}
+
+	flags[i] = value
+	return nil
+}
+
+// Run returns an optimized copy of the program. The program must not be
+// linked yet, since the passes change the instruction indexes.
+func (p *Pipeline) Run(program []parser.Instruction) []parser.Instruction {
+	for i, pass := range passes {
+		if p.before[i] {
+			p.dumpProgram("before", pass.Name, program)
+		}
+
+		if p.enabled[i] {
+			program = p.runPass(pass, program)
+		}
+
+		if p.after[i] {
+			p.dumpProgram("after", pass.Name, program)
+		}
+	}
+
+	return program
+}
+
+// runPass runs a single pass of the pipeline. Balanced loops that only
+// decrement their own cell are clear loops too, so muladd keeps them when the
+// clear pass is disabled.
+func (p *Pipeline) runPass(pass Pass, program []parser.Instruction) []parser.Instruction {
+	if i, _ := findPass("clear"); pass.Name == "muladd" && !p.enabled[i] {
+		return mulLoops(program, false)
+	}
+
+	return pass.Run(program)
+}
+
+func (p *Pipeline) dumpProgram(when, name string, program []parser.Instruction) {
+	if p.dump == nil {
+		return
+	}
+
+	fmt.Fprintf(p.dump, "; %v %v\n", when, name)
+	Dump(p.dump, program)
+}
+
+// Dump writes the program to w, one instruction per line
+func Dump(w io.Writer, program []parser.Instruction) {
+	for i, ins := range program {
+		fmt.Fprintf(w, "%04d %v\n", i, ins)
+	}
+}
+
+// Optimize returns an optimized copy of the program, with every pass
+// enabled. The program must not be linked yet, since the passes change the
+// instruction indexes.
+func Optimize(program []parser.Instruction) []parser.Instruction {
+	p, _ := New(MaxLevel)
+	return p.Run(program)
+}
+
+// spanOf returns a span covering all the specified instructions
+func spanOf(instructions []parser.Instruction) parser.Span {
+	return parser.Span{
+		Start: instructions[0].Span.Start,
+		End:   instructions[len(instructions)-1].Span.End,
+	}
+}
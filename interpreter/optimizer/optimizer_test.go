@@ -0,0 +1,145 @@
+package optimizer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+)
+
+func TestPipelineLevels(t *testing.T) {
+	testCases := []struct {
+		level    int
+		expected string
+	}{
+		{level: 0, expected: ``},
+		{level: 1, expected: `clear,scan`},
+		{level: 2, expected: `clear,muladd,scan`},
+		{level: 3, expected: `clear,muladd,scan,offsets`},
+	}
+
+	for i, test := range testCases {
+		p, err := optimizer.New(test.level)
+
+		if err != nil {
+			t.Fatalf("Case %v, unexpected error: %v", i, err)
+		}
+
+		if received := strings.Join(p.Enabled(), ","); received != test.expected {
+			t.Errorf("Case %v, received \"%v\", expected \"%v\"", i, received, test.expected)
+		}
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	for _, level := range []int{-1, optimizer.MaxLevel + 1} {
+		if _, err := optimizer.New(level); err == nil {
+			t.Errorf("Level %v, expected an error", level)
+		} else if _, ok := err.(optimizer.InvalidLevelError); !ok {
+			t.Errorf("Level %v, wrong error type. Expected \"InvalidLevelError\", received \"%T\"", level, err)
+		}
+	}
+
+	p, _ := optimizer.New(optimizer.MaxLevel)
+
+	for i, fn := range []func(string) error{p.Enable, p.Disable, p.DumpBefore, p.DumpAfter} {
+		err := fn("nope")
+
+		if e2, ok := err.(optimizer.UnknownPassError); !ok {
+			t.Errorf("Case %v, wrong error type. Expected \"UnknownPassError\", received \"%T\"", i, err)
+		} else if !strings.Contains(e2.Error(), "nope") {
+			t.Errorf("Case %v, wrong error message. Received \"%v\"", i, e2.Error())
+		}
+	}
+}
+
+func TestPipelineRun(t *testing.T) {
+	source := `>>[-]<<[->>+<<]>[>]`
+	testCases := []struct {
+		level    int
+		enable   []string
+		disable  []string
+		expected string
+	}{
+		{level: 0, expected: dump(parse(t, source))},
+		{level: 3, expected: `clear @+2; muladd 1 @+2; clear; move 1; scan 1`},
+		{level: 3, disable: []string{"muladd"}, expected: `clear @+2; jump 0; add -1; add 1 @+2; return 0; move 1; scan 1`},
+		{level: 1, expected: `move 2; clear; move -2; jump 0; add -1; move 2; add 1; move -2; return 0; move 1; scan 1`},
+		{level: 3, disable: []string{"clear"}, expected: `move 2; jump 0; add -1; return 0; move -2; muladd 1 @+2; clear; move 1; scan 1`},
+		{level: 0, enable: []string{"scan"}, expected: `move 2; jump 0; add -1; return 0; move -2; jump 0; add -1; move 2; add 1; move -2; return 0; move 1; scan 1`},
+	}
+
+	for i, test := range testCases {
+		p, _ := optimizer.New(test.level)
+
+		for _, name := range test.enable {
+			p.Enable(name)
+		}
+
+		for _, name := range test.disable {
+			p.Disable(name)
+		}
+
+		if result := dump(p.Run(parse(t, source))); result != test.expected {
+			t.Errorf("Case %v, received \"%v\", expected \"%v\"", i, result, test.expected)
+		}
+	}
+}
+
+func TestPipelineDump(t *testing.T) {
+	p, _ := optimizer.New(1)
+	out := strings.Builder{}
+	p.SetDumpOutput(&out)
+	p.DumpBefore("clear")
+	p.DumpAt("after:clear")
+	p.DumpAt("after:muladd")
+
+	p.Run(parse(t, `+[-]`))
+	expected := "; before clear\n0000 add 1\n0001 jump 0\n0002 add -1\n0003 return 0\n" +
+		"; after clear\n0000 add 1\n0001 clear\n" +
+		"; after muladd\n0000 add 1\n0001 clear\n"
+
+	if out.String() != expected {
+		t.Errorf("Received \"%v\", expected \"%v\"", out.String(), expected)
+	}
+}
+
+func TestPipelineDumpAtErrors(t *testing.T) {
+	p, _ := optimizer.New(optimizer.MaxLevel)
+
+	for i, spec := range []string{"clear", "during:clear", "after"} {
+		if _, ok := p.DumpAt(spec).(optimizer.InvalidDumpError); !ok {
+			t.Errorf("Case %v, expected an \"InvalidDumpError\"", i)
+		}
+	}
+
+	if _, ok := p.DumpAt("after:nope").(optimizer.UnknownPassError); !ok {
+		t.Errorf("Expected an \"UnknownPassError\"")
+	}
+}
+
+func TestPipelineWithoutWrapping(t *testing.T) {
+	p, _ := optimizer.New(optimizer.MaxLevel)
+	other := p.WithoutWrapping()
+
+	if received := strings.Join(other.Enabled(), ","); received != "scan,offsets" {
+		t.Errorf("Received \"%v\", expected \"scan,offsets\"", received)
+	}
+
+	if received := strings.Join(p.Enabled(), ","); received != "clear,muladd,scan,offsets" {
+		t.Errorf("The original pipeline should not change, received \"%v\"", received)
+	}
+}
+
+func TestPipelineWithoutFoldedMoves(t *testing.T) {
+	p, _ := optimizer.New(optimizer.MaxLevel)
+	other := p.WithoutFoldedMoves()
+
+	if received := strings.Join(other.Enabled(), ","); received != "clear,scan" {
+		t.Errorf("Received \"%v\", expected \"clear,scan\"", received)
+	}
+
+	if received := strings.Join(p.Enabled(), ","); received != "clear,muladd,scan,offsets" {
+		t.Errorf("The original pipeline should not change, received \"%v\"", received)
+	}
+}
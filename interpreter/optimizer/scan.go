@@ -0,0 +1,31 @@
+package optimizer
+
+import (
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+// ScanLoops replaces loops like [>], [<] and [>>>>] with a single CmdScan
+// instruction, that moves the pointer by the loop stride until a zero cell
+// is found.
+func ScanLoops(program []parser.Instruction) []parser.Instruction {
+	result := make([]parser.Instruction, 0, len(program))
+
+	for i := 0; i < len(program); i++ {
+		if isScanLoop(program[i:]) {
+			result = append(result, parser.Instruction{Cmd: parser.CmdScan, Arg: program[i+1].Arg, Span: spanOf(program[i : i+3])})
+			i += 2
+			continue
+		}
+
+		result = append(result, program[i])
+	}
+
+	return result
+}
+
+func isScanLoop(program []parser.Instruction) bool {
+	return len(program) >= 3 &&
+		program[0].Cmd == parser.CmdJump &&
+		program[1].Cmd == parser.CmdMove && program[1].Arg != 0 &&
+		program[2].Cmd == parser.CmdReturn
+}
@@ -0,0 +1,30 @@
+package optimizer_test
+
+import (
+	"testing"
+
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+)
+
+func TestScanLoops(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+	}{
+		{source: `[>]`, expected: `scan 1`},
+		{source: `[<]`, expected: `scan -1`},
+		{source: `+[>>>>]-[<<]`, expected: `add 1; scan 4; add -1; scan -2`},
+		{source: `[[>]]`, expected: `jump 0; scan 1; return 0`},
+		{source: `[>+]`, expected: `jump 0; move 1; add 1; return 0`},
+		{source: `[><]`, expected: `jump 0; move 1; move -1; return 0`},
+	}
+
+	for i, test := range testCases {
+		program := parse(t, test.source)
+		result := dump(optimizer.ScanLoops(program))
+
+		if result != test.expected {
+			t.Errorf("Case %v, received \"%v\", expected \"%v\"", i, result, test.expected)
+		}
+	}
+}
@@ -1,67 +1,115 @@
 package parser
 
 import (
-	"github.com/ibraimgm/bfi/interpreter/token"
+	"fmt"
 )
 
-const maskCmdType byte = 3 << 6
-const maskCmdQty byte = 0xFF >> 2
+// Command identifies the operation performed by an Instruction
+type Command byte
 
 // List of constants representing the parsed commands
 const (
-	CmdMoveRight byte = 0
-	CmdMoveLeft  byte = 1
-	CmdInc       byte = 2
-	CmdDec       byte = 3
-
-	CmdOutput byte = 0
-	CmdInput  byte = 1
-	CmdJump   byte = 2
-	CmdReturn byte = 3
+	// CmdMove moves the tape pointer by Arg cells. Negative values move
+	// the pointer to the left.
+	CmdMove Command = iota
+
+	// CmdAdd adds Arg to the current cell. Negative values subtract from it.
+	CmdAdd
+
+	// CmdOutput writes the value of the current cell.
+	CmdOutput
+
+	// CmdInput reads a value into the current cell.
+	CmdInput
+
+	// CmdJump goes past the matching CmdReturn if the current cell is zero.
+	// Once the program is linked, Arg holds the index of the matching CmdReturn.
+	CmdJump
+
+	// CmdReturn jumps back to the matching CmdJump if the current cell is nonzero.
+	// Once the program is linked, Arg holds the index of the matching CmdJump.
+	CmdReturn
+
+	// CmdClear sets the current cell to zero. It is never produced by Parse, only
+	// by the optimizer.
+	CmdClear
+
+	// CmdMulAdd adds Arg times the value of the current cell to the cell at
+	// Offset. It is never produced by Parse, only by the optimizer.
+	CmdMulAdd
+
+	// CmdScan moves the tape pointer by Arg cells until it finds a cell with
+	// zero. It is never produced by Parse, only by the optimizer.
+	CmdScan
 )
 
-// ExtractCommand return the relevant byte parts of the identified command
-// and the number of times that the command repeats
-func ExtractCommand(value byte) (byte, byte) {
-	return (value & maskCmdType) >> 6, value & maskCmdQty
+var commandNames = map[Command]string{
+	CmdMove:   "move",
+	CmdAdd:    "add",
+	CmdOutput: "output",
+	CmdInput:  "input",
+	CmdJump:   "jump",
+	CmdReturn: "return",
+	CmdClear:  "clear",
+	CmdMulAdd: "muladd",
+	CmdScan:   "scan",
+}
+
+func (c Command) String() string {
+	if name, ok := commandNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("command(%d)", byte(c))
 }
 
-// EncodeCommand return the byte equivalent of the command represented by
-// cmdToken, repeated by qty times. Non-repeating commands ignore the value of
-// qty.
-func EncodeCommand(cmdToken rune, qty byte) byte {
-	var cmd byte
-	q := qty
-
-	switch cmdToken {
-	case token.MoveRight:
-		cmd = CmdMoveRight
-	case token.MoveLeft:
-		cmd = CmdMoveLeft
-	case token.Inc:
-		cmd = CmdInc
-	case token.Dec:
-		cmd = CmdDec
-	case token.Output:
-		cmd = CmdOutput
-	case token.Input:
-		cmd = CmdInput
-	case token.Jump:
-		cmd = CmdJump
-	case token.Return:
-		cmd = CmdReturn
+// Position is a location in the source code. Offset counts bytes from the
+// start of the source, while Line and Column start at 1 and count lines
+// and runes, respectively.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span is the range of source code, from Start (inclusive) to End (exclusive),
+// that generated an instruction
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%v-%v", s.Start, s.End)
+}
+
+// Instruction is a single command of a parsed program, with its operands and the
+// span of source code it came from. Offset is the position of the target cell,
+// relative to the tape pointer.
+type Instruction struct {
+	Cmd    Command
+	Arg    int
+	Offset int
+	Span   Span
+}
+
+func (ins Instruction) String() string {
+	var s string
+
+	switch ins.Cmd {
+	case CmdOutput, CmdInput, CmdClear:
+		s = ins.Cmd.String()
+	default:
+		s = fmt.Sprintf("%v %d", ins.Cmd, ins.Arg)
 	}
 
-	switch cmdToken {
-	case token.Output:
-		fallthrough
-	case token.Input:
-		fallthrough
-	case token.Jump:
-		fallthrough
-	case token.Return:
-		q = 0
+	if ins.Offset != 0 {
+		s += fmt.Sprintf(" @%+d", ins.Offset)
 	}
 
-	return (cmd << 6) | q
+	return s
 }
@@ -4,58 +4,30 @@ import (
 	"testing"
 
 	"github.com/ibraimgm/bfi/interpreter/parser"
-	"github.com/ibraimgm/bfi/interpreter/token"
 )
 
-func TestExtractCommand(t *testing.T) {
+func TestInstructionString(t *testing.T) {
 	testCases := []struct {
-		value, command, quantity byte
+		ins      parser.Instruction
+		expected string
 	}{
-		{value: 34, command: parser.CmdMoveRight, quantity: 34},
-		{value: 109, command: parser.CmdMoveLeft, quantity: 45},
-		{value: 138, command: parser.CmdInc, quantity: 10},
-		{value: 193, command: parser.CmdDec, quantity: 1},
-		{value: 0, command: parser.CmdOutput},
-		{value: 64, command: parser.CmdInput},
-		{value: 128, command: parser.CmdJump},
-		{value: 192, command: parser.CmdReturn},
+		{ins: parser.Instruction{Cmd: parser.CmdMove, Arg: 3}, expected: "move 3"},
+		{ins: parser.Instruction{Cmd: parser.CmdMove, Arg: -2}, expected: "move -2"},
+		{ins: parser.Instruction{Cmd: parser.CmdAdd, Arg: 65}, expected: "add 65"},
+		{ins: parser.Instruction{Cmd: parser.CmdOutput}, expected: "output"},
+		{ins: parser.Instruction{Cmd: parser.CmdInput}, expected: "input"},
+		{ins: parser.Instruction{Cmd: parser.CmdJump, Arg: 7}, expected: "jump 7"},
+		{ins: parser.Instruction{Cmd: parser.CmdReturn, Arg: 1}, expected: "return 1"},
+		{ins: parser.Instruction{Cmd: parser.CmdClear}, expected: "clear"},
+		{ins: parser.Instruction{Cmd: parser.CmdMulAdd, Arg: 2, Offset: 1}, expected: "muladd 2 @+1"},
+		{ins: parser.Instruction{Cmd: parser.CmdMulAdd, Arg: -1, Offset: -3}, expected: "muladd -1 @-3"},
+		{ins: parser.Instruction{Cmd: parser.CmdScan, Arg: -4}, expected: "scan -4"},
+		{ins: parser.Instruction{Cmd: parser.Command(200)}, expected: "command(200) 0"},
 	}
 
 	for i, test := range testCases {
-		cmd, qty := parser.ExtractCommand(test.value)
-
-		if cmd != test.command {
-			t.Errorf("Case %v, received command \"%v\", expected \"%v\"", i, cmd, test.command)
-		}
-
-		if qty != test.quantity {
-			t.Errorf("Case %v, received quantity \"%v\", expected \"%v\"", i, qty, test.quantity)
-		}
-	}
-
-}
-
-func TestEncodeCommand(t *testing.T) {
-	testCases := []struct {
-		cmd      rune
-		qty      byte
-		expected byte
-	}{
-		{cmd: token.MoveRight, qty: 63, expected: 63},
-		{cmd: token.MoveLeft, qty: 63, expected: 127},
-		{cmd: token.Inc, qty: 4, expected: 132},
-		{cmd: token.Dec, qty: 3, expected: 195},
-		{cmd: token.Output, qty: 7, expected: 0},
-		{cmd: token.Input, qty: 2, expected: 64},
-		{cmd: token.Jump, qty: 6, expected: 128},
-		{cmd: token.Return, qty: 1, expected: 192},
-	}
-
-	for i, test := range testCases {
-		encoded := parser.EncodeCommand(test.cmd, test.qty)
-
-		if encoded != test.expected {
-			t.Errorf("Case %v, token %v, received \"%v\", expected \"%v\"", i, test.cmd, encoded, test.expected)
+		if s := test.ins.String(); s != test.expected {
+			t.Errorf("Case %v, received \"%v\", expected \"%v\"", i, s, test.expected)
 		}
 	}
 }
@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnmatchedBracketError indicates a [ without a matching ], or a ] without
+// a matching [.
+type UnmatchedBracketError struct {
+	Token rune
+	Pos   Position
+}
+
+func (err UnmatchedBracketError) Error() string {
+	return fmt.Sprintf("%v: unmatched '%c'", err.Pos, err.Token)
+}
+
+// ErrorList holds every error found while parsing the source code, in the
+// order they appear.
+type ErrorList []error
+
+func (list ErrorList) Error() string {
+	msgs := make([]string, len(list))
+
+	for i, err := range list {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors in the list, so they can be inspected with
+// errors.Is and errors.As.
+func (list ErrorList) Unwrap() []error {
+	return list
+}
@@ -3,8 +3,8 @@ package parser
 import (
 	"bufio"
 	"io"
+	"sort"
 
-	"github.com/ibraimgm/bfi/interpreter"
 	"github.com/ibraimgm/bfi/interpreter/token"
 )
 
@@ -12,140 +12,163 @@ const emptyToken = '\x00'
 
 type parseState struct {
 	reader  *bufio.Reader
-	buffer  []byte
-	maxSize int
-	current int
+	program []Instruction
+	pos     Position
+	next    Position
 	lastCmd rune
-	lastQty byte
+	open    []Position
+	errors  []UnmatchedBracketError
 }
 
-func initState(buffer []byte, reader *bufio.Reader) *parseState {
-	return &parseState{reader, buffer, len(buffer), 0, emptyToken, 0}
+func initState(reader *bufio.Reader) *parseState {
+	start := Position{Offset: 0, Line: 1, Column: 1}
+	return &parseState{reader, make([]Instruction, 0), start, start, emptyToken, make([]Position, 0), make([]UnmatchedBracketError, 0)}
 }
 
+// read returns the next byte of the source, keeping track of its position.
+// Columns are counted in runes, so UTF-8 continuation bytes do not advance them.
 func (s *parseState) read() (rune, error) {
 	b, err := s.reader.ReadByte()
-	return rune(b), err
-}
 
-func (s *parseState) encodeCombined() {
-	s.buffer[s.current] = EncodeCommand(s.lastCmd, s.lastQty)
-	s.current++
-	s.lastQty = 0
-}
+	if err == nil {
+		s.pos = s.next
+		s.next.Offset++
 
-func (s *parseState) undoWhenOverflow() (int, bool, error) {
-	if s.current == s.maxSize {
-		err := s.reader.UnreadByte()
-		return s.current, true, err
+		if b == '\n' {
+			s.next.Line++
+			s.next.Column = 1
+		} else if b&0xC0 != 0x80 {
+			s.next.Column++
+		}
 	}
 
-	return s.current, false, nil
+	return rune(b), err
 }
 
-func (s *parseState) combineCommand() (int, bool, error) {
-	if s.lastQty == 63 {
-		s.encodeCombined()
+// checkBracket keeps track of the open brackets, recording an error for every
+// ] without a matching [
+func (s *parseState) checkBracket(currToken rune) {
+	switch currToken {
+	case token.Jump:
+		s.open = append(s.open, s.pos)
+	case token.Return:
+		if len(s.open) == 0 {
+			s.errors = append(s.errors, UnmatchedBracketError{Token: currToken, Pos: s.pos})
+		} else {
+			s.open = s.open[:len(s.open)-1]
+		}
 	}
+}
 
-	read, undo, err := s.undoWhenOverflow()
+// finish records an error for every [ left without a matching ]
+func (s *parseState) finish() error {
+	for _, pos := range s.open {
+		s.errors = append(s.errors, UnmatchedBracketError{Token: token.Jump, Pos: pos})
+	}
 
-	if !undo {
-		s.lastQty++
+	if len(s.errors) == 0 {
+		return nil
 	}
 
-	return read, undo, err
-}
+	sort.Slice(s.errors, func(i, j int) bool {
+		return s.errors[i].Pos.Offset < s.errors[j].Pos.Offset
+	})
 
-func (s *parseState) commitPending() (int, bool, error) {
-	if s.hasPendingCommand() {
-		s.encodeCombined()
-		return s.undoWhenOverflow()
+	list := make(ErrorList, len(s.errors))
+	for i, err := range s.errors {
+		list[i] = err
 	}
 
-	return s.current, false, nil
+	return list
 }
 
 func (s *parseState) shouldCombine(currToken rune) bool {
 	return currToken == s.lastCmd
 }
 
-func (s *parseState) hasPendingCommand() bool {
-	return s.lastCmd != emptyToken && s.lastQty > 0
+// combine folds the token into the last emitted instruction
+func (s *parseState) combine(currToken rune) {
+	last := &s.program[len(s.program)-1]
+	last.Arg += tokenArg(currToken)
+	last.Span.End = s.next
 }
 
-func (s *parseState) initCombined(currToken rune) {
-	s.lastCmd = currToken
-	s.lastQty = 1
+func (s *parseState) encode(currToken rune) {
+	s.program = append(s.program, Instruction{
+		Cmd:  tokenCommand(currToken),
+		Arg:  tokenArg(currToken),
+		Span: Span{Start: s.pos, End: s.next},
+	})
+
+	switch currToken {
+	case token.MoveRight, token.MoveLeft, token.Inc, token.Dec:
+		s.lastCmd = currToken
+	default:
+		s.lastCmd = emptyToken
+	}
 }
 
-func (s *parseState) encode(currToken rune) {
-	s.buffer[s.current] = EncodeCommand(currToken, 0)
-	s.lastCmd = emptyToken
-	s.current++
+func tokenCommand(currToken rune) Command {
+	switch currToken {
+	case token.MoveRight, token.MoveLeft:
+		return CmdMove
+	case token.Inc, token.Dec:
+		return CmdAdd
+	case token.Output:
+		return CmdOutput
+	case token.Input:
+		return CmdInput
+	case token.Jump:
+		return CmdJump
+	default:
+		return CmdReturn
+	}
 }
 
-type parseReader struct {
-	r *bufio.Reader
+func tokenArg(currToken rune) int {
+	switch currToken {
+	case token.MoveRight, token.Inc:
+		return 1
+	case token.MoveLeft, token.Dec:
+		return -1
+	default:
+		return 0
+	}
 }
 
-func (p *parseReader) Read(buffer []byte) (int, error) {
-	st := initState(buffer, p.r)
+// Parse reads the source code and returns the list of instructions it represents.
+// Non-command characters are ignored, and runs of the same move or arithmetic
+// command are folded into a single instruction.
+//
+// Every unmatched bracket is reported, with its position, in an ErrorList.
+// Jump and return instructions are not linked; it is up to the caller to
+// fill their Arg with the index of the matching instruction.
+func Parse(source io.Reader) ([]Instruction, error) {
+	st := initState(bufio.NewReader(source))
 
 	for {
-		//check if we can read a new token
-		if currToken, err := st.read(); err == nil {
-
-			// if possible combine the read token, until the 6-bit limit
-			// might undo the read if the buffer overflows
-			if st.shouldCombine(currToken) {
-				if read, undo, err := st.combineCommand(); undo {
-					return read, err
-				}
-			} else {
-				// if it is not combinable, commit the last pending combinable command.
-				// this might undo the read if the buffer overflows
-				if read, undo, err := st.commitPending(); undo {
-					return read, err
-				}
-
-				// actual processing of a new (or non-combinable) token
-				// this might either start a new combination or directly encode the
-				// token, if it is not combinable
-				switch currToken {
-				case token.MoveRight:
-					fallthrough
-				case token.MoveLeft:
-					fallthrough
-				case token.Inc:
-					fallthrough
-				case token.Dec:
-					st.initCombined(currToken)
-				default:
-					st.encode(currToken)
-				}
-			}
-		} else {
-			// in case of error or EOF, finish any remaining pending command
-			// before exiting
-			if st.hasPendingCommand() {
-				st.encodeCombined()
+		currToken, err := st.read()
+
+		if err == io.EOF {
+			if err := st.finish(); err != nil {
+				return nil, err
 			}
 
-			return st.current, err
+			return st.program, nil
+		} else if err != nil {
+			return nil, err
 		}
 
-		// check for a filled buffer
-		if st.current >= st.maxSize {
-			return st.current, nil
+		if !token.IsValid(currToken) {
+			continue
 		}
-	}
-}
 
-// Parse returns a new io.Reader that transform the source code into a smaller
-// representation of itself
-func Parse(source io.Reader) io.Reader {
-	minified := interpreter.Minify(source)
-	buffered := bufio.NewReader(minified)
-	return &parseReader{buffered}
+		st.checkBracket(currToken)
+
+		if st.shouldCombine(currToken) {
+			st.combine(currToken)
+		} else {
+			st.encode(currToken)
+		}
+	}
 }
@@ -1,7 +1,7 @@
 package parser_test
 
 import (
-	"bytes"
+	"errors"
 	"strings"
 	"testing"
 
@@ -9,8 +9,8 @@ import (
 )
 
 type expectedCommand struct {
-	cmdType byte
-	cmdQty  byte
+	cmd parser.Command
+	arg int
 }
 
 func TestCompile(t *testing.T) {
@@ -21,186 +21,242 @@ func TestCompile(t *testing.T) {
 		{
 			source: `++++++++[>++++[>++>+++>+++>+<<<<-]>+>+>->>+[<]<-]>>.>---.+++++++..+++.>>.<-.<.+++.------.--------.>>+.>++.`,
 			commands: []expectedCommand{
-				{cmdType: parser.CmdInc, cmdQty: 8},
-				{cmdType: parser.CmdJump},
-				{cmdType: parser.CmdMoveRight, cmdQty: 1},
-				{cmdType: parser.CmdInc, cmdQty: 4},
-				{cmdType: parser.CmdJump},
-				{cmdType: parser.CmdMoveRight, cmdQty: 1},
-				{cmdType: parser.CmdInc, cmdQty: 2},
-				{cmdType: parser.CmdMoveRight, cmdQty: 1},
-				{cmdType: parser.CmdInc, cmdQty: 3},
-				{cmdType: parser.CmdMoveRight, cmdQty: 1},
-				{cmdType: parser.CmdInc, cmdQty: 3},
-				{cmdType: parser.CmdMoveRight, cmdQty: 1},
-				{cmdType: parser.CmdInc, cmdQty: 1},
-				{cmdType: parser.CmdMoveLeft, cmdQty: 4},
-				{cmdType: parser.CmdDec, cmdQty: 1},
-				{cmdType: parser.CmdReturn},
-				{cmdType: parser.CmdMoveRight, cmdQty: 1},
-				{cmdType: parser.CmdInc, cmdQty: 1},
-				{cmdType: parser.CmdMoveRight, cmdQty: 1},
-				{cmdType: parser.CmdInc, cmdQty: 1},
-				{cmdType: parser.CmdMoveRight, cmdQty: 1},
-				{cmdType: parser.CmdDec, cmdQty: 1},
-				{cmdType: parser.CmdMoveRight, cmdQty: 2},
-				{cmdType: parser.CmdInc, cmdQty: 1},
-				{cmdType: parser.CmdJump},
-				{cmdType: parser.CmdMoveLeft, cmdQty: 1},
-				{cmdType: parser.CmdReturn},
-				{cmdType: parser.CmdMoveLeft, cmdQty: 1},
-				{cmdType: parser.CmdDec, cmdQty: 1},
-				{cmdType: parser.CmdReturn},
-				{cmdType: parser.CmdMoveRight, cmdQty: 2},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdMoveRight, cmdQty: 1},
-				{cmdType: parser.CmdDec, cmdQty: 3},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdInc, cmdQty: 7},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdInc, cmdQty: 3},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdMoveRight, cmdQty: 2},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdMoveLeft, cmdQty: 1},
-				{cmdType: parser.CmdDec, cmdQty: 1},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdMoveLeft, cmdQty: 1},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdInc, cmdQty: 3},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdDec, cmdQty: 6},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdDec, cmdQty: 8},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdMoveRight, cmdQty: 2},
-				{cmdType: parser.CmdInc, cmdQty: 1},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdMoveRight, cmdQty: 1},
-				{cmdType: parser.CmdInc, cmdQty: 2},
-				{cmdType: parser.CmdOutput},
+				{cmd: parser.CmdAdd, arg: 8},
+				{cmd: parser.CmdJump},
+				{cmd: parser.CmdMove, arg: 1},
+				{cmd: parser.CmdAdd, arg: 4},
+				{cmd: parser.CmdJump},
+				{cmd: parser.CmdMove, arg: 1},
+				{cmd: parser.CmdAdd, arg: 2},
+				{cmd: parser.CmdMove, arg: 1},
+				{cmd: parser.CmdAdd, arg: 3},
+				{cmd: parser.CmdMove, arg: 1},
+				{cmd: parser.CmdAdd, arg: 3},
+				{cmd: parser.CmdMove, arg: 1},
+				{cmd: parser.CmdAdd, arg: 1},
+				{cmd: parser.CmdMove, arg: -4},
+				{cmd: parser.CmdAdd, arg: -1},
+				{cmd: parser.CmdReturn},
+				{cmd: parser.CmdMove, arg: 1},
+				{cmd: parser.CmdAdd, arg: 1},
+				{cmd: parser.CmdMove, arg: 1},
+				{cmd: parser.CmdAdd, arg: 1},
+				{cmd: parser.CmdMove, arg: 1},
+				{cmd: parser.CmdAdd, arg: -1},
+				{cmd: parser.CmdMove, arg: 2},
+				{cmd: parser.CmdAdd, arg: 1},
+				{cmd: parser.CmdJump},
+				{cmd: parser.CmdMove, arg: -1},
+				{cmd: parser.CmdReturn},
+				{cmd: parser.CmdMove, arg: -1},
+				{cmd: parser.CmdAdd, arg: -1},
+				{cmd: parser.CmdReturn},
+				{cmd: parser.CmdMove, arg: 2},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdMove, arg: 1},
+				{cmd: parser.CmdAdd, arg: -3},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdAdd, arg: 7},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdAdd, arg: 3},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdMove, arg: 2},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdMove, arg: -1},
+				{cmd: parser.CmdAdd, arg: -1},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdMove, arg: -1},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdAdd, arg: 3},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdAdd, arg: -6},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdAdd, arg: -8},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdMove, arg: 2},
+				{cmd: parser.CmdAdd, arg: 1},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdMove, arg: 1},
+				{cmd: parser.CmdAdd, arg: 2},
+				{cmd: parser.CmdOutput},
 			},
 		},
 		{
 			source: `>>[-]<<[->>+<<]`,
 			commands: []expectedCommand{
-				{cmdType: parser.CmdMoveRight, cmdQty: 2},
-				{cmdType: parser.CmdJump},
-				{cmdType: parser.CmdDec, cmdQty: 1},
-				{cmdType: parser.CmdReturn},
-				{cmdType: parser.CmdMoveLeft, cmdQty: 2},
-				{cmdType: parser.CmdJump},
-				{cmdType: parser.CmdDec, cmdQty: 1},
-				{cmdType: parser.CmdMoveRight, cmdQty: 2},
-				{cmdType: parser.CmdInc, cmdQty: 1},
-				{cmdType: parser.CmdMoveLeft, cmdQty: 2},
-				{cmdType: parser.CmdReturn},
+				{cmd: parser.CmdMove, arg: 2},
+				{cmd: parser.CmdJump},
+				{cmd: parser.CmdAdd, arg: -1},
+				{cmd: parser.CmdReturn},
+				{cmd: parser.CmdMove, arg: -2},
+				{cmd: parser.CmdJump},
+				{cmd: parser.CmdAdd, arg: -1},
+				{cmd: parser.CmdMove, arg: 2},
+				{cmd: parser.CmdAdd, arg: 1},
+				{cmd: parser.CmdMove, arg: -2},
+				{cmd: parser.CmdReturn},
 			},
 		},
 		{
 			source: `+++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++`,
 			commands: []expectedCommand{
-				{cmdType: parser.CmdInc, cmdQty: 63},
-				{cmdType: parser.CmdInc, cmdQty: 2},
+				{cmd: parser.CmdAdd, arg: 65},
+			},
+		},
+		{
+			source: `...,,+++,`,
+			commands: []expectedCommand{
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdOutput},
+				{cmd: parser.CmdInput},
+				{cmd: parser.CmdInput},
+				{cmd: parser.CmdAdd, arg: 3},
+				{cmd: parser.CmdInput},
 			},
 		},
 	}
 
 	for i, test := range testCases {
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(parser.Parse(strings.NewReader(test.source)))
-		compiled := buf.Bytes()
+		compiled, err := parser.Parse(strings.NewReader(test.source))
+
+		if err != nil {
+			t.Errorf("Case %v, unexpected error: \"%v\"", i, err)
+		}
 
 		if len(compiled) != len(test.commands) {
 			t.Errorf("Case %v, mismatched size. Received \"%v\", expected \"%v\"", i, len(compiled), len(test.commands))
+			continue
 		}
 
-		for j, value := range compiled {
-			cmd, qty := parser.ExtractCommand(value)
-
-			if cmd != test.commands[j].cmdType {
-				t.Errorf("Case %v, byte %v, command mismatch. Received \"%v\", expected \"%v\"", i, j, cmd, test.commands[j].cmdType)
+		for j, ins := range compiled {
+			if ins.Cmd != test.commands[j].cmd {
+				t.Errorf("Case %v, instruction %v, command mismatch. Received \"%v\", expected \"%v\"", i, j, ins.Cmd, test.commands[j].cmd)
 			}
 
-			if qty != test.commands[j].cmdQty {
-				t.Errorf("Case %v, byte %v, qty mismatch. Received \"%v\", expected \"%v\"", i, j, qty, test.commands[j].cmdQty)
+			if ins.Arg != test.commands[j].arg {
+				t.Errorf("Case %v, instruction %v, arg mismatch. Received \"%v\", expected \"%v\"", i, j, ins.Arg, test.commands[j].arg)
 			}
 		}
 	}
 }
 
-func TestCompileWithBuffer(t *testing.T) {
+func TestParseSpans(t *testing.T) {
 	testCases := []struct {
-		source   string
-		commands []expectedCommand
+		source string
+		spans  []string
 	}{
 		{
 			source: `+++-+++-+`,
-			commands: []expectedCommand{
-				{cmdType: parser.CmdInc, cmdQty: 3},
-				{cmdType: parser.CmdDec, cmdQty: 1},
-				{cmdType: parser.CmdInc, cmdQty: 3},
-				{cmdType: parser.CmdDec, cmdQty: 1},
-				{cmdType: parser.CmdInc, cmdQty: 1},
-			},
+			spans:  []string{"1:1-1:4", "1:4-1:5", "1:5-1:8", "1:8-1:9", "1:9-1:10"},
 		},
 		{
-			source: `...,,+++,`,
-			commands: []expectedCommand{
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdInput},
-				{cmdType: parser.CmdInput},
-				{cmdType: parser.CmdInc, cmdQty: 3},
-				{cmdType: parser.CmdInput},
-			},
+			source: `a .. b`,
+			spans:  []string{"1:3-1:4", "1:4-1:5"},
 		},
 		{
-			source: `..+++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++`,
-			commands: []expectedCommand{
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdOutput},
-				{cmdType: parser.CmdInc, cmdQty: 63},
-				{cmdType: parser.CmdInc, cmdQty: 2},
-			},
+			source: "++ comment\n++[>]",
+			spans:  []string{"1:1-2:3", "2:3-2:4", "2:4-2:5", "2:5-2:6"},
 		},
 	}
 
 	for i, test := range testCases {
-		buffer := make([]byte, 3)
-		compiled := make([]byte, len(test.commands))
-		reader := parser.Parse(strings.NewReader(test.source))
-		idx := 0
+		compiled, err := parser.Parse(strings.NewReader(test.source))
 
-		for {
-			n, err := reader.Read(buffer)
+		if err != nil {
+			t.Errorf("Case %v, unexpected error: \"%v\"", i, err)
+		}
 
-			for j := 0; j < n; j++ {
-				compiled[idx] = buffer[j]
-				idx++
-			}
+		if len(compiled) != len(test.spans) {
+			t.Errorf("Case %v, mismatched size. Received \"%v\", expected \"%v\"", i, len(compiled), len(test.spans))
+			continue
+		}
 
-			if err != nil {
-				break
+		for j, ins := range compiled {
+			if ins.Span.String() != test.spans[j] {
+				t.Errorf("Case %v, instruction %v, span mismatch. Received \"%v\", expected \"%v\"", i, j, ins.Span, test.spans[j])
 			}
 		}
+	}
+}
 
-		if len(compiled) != len(test.commands) {
-			t.Errorf("Case %v, mismatched size. Received \"%v\", expected \"%v\"", i, len(compiled), len(test.commands))
+func TestParseBracketErrors(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected []parser.UnmatchedBracketError
+	}{
+		{
+			source:   `[[]`,
+			expected: []parser.UnmatchedBracketError{{Token: '[', Pos: parser.Position{Offset: 0, Line: 1, Column: 1}}},
+		},
+		{
+			source:   "+\n-]",
+			expected: []parser.UnmatchedBracketError{{Token: ']', Pos: parser.Position{Offset: 3, Line: 2, Column: 2}}},
+		},
+		{
+			source: "]\n  [ ]] ççç [\n[",
+			expected: []parser.UnmatchedBracketError{
+				{Token: ']', Pos: parser.Position{Offset: 0, Line: 1, Column: 1}},
+				{Token: ']', Pos: parser.Position{Offset: 7, Line: 2, Column: 6}},
+				{Token: '[', Pos: parser.Position{Offset: 16, Line: 2, Column: 12}},
+				{Token: '[', Pos: parser.Position{Offset: 18, Line: 3, Column: 1}},
+			},
+		},
+	}
+
+	for i, test := range testCases {
+		program, err := parser.Parse(strings.NewReader(test.source))
+
+		if program != nil {
+			t.Errorf("Case %v, expected no program, received \"%v\"", i, program)
 		}
 
-		for j, value := range compiled {
-			cmd, qty := parser.ExtractCommand(value)
+		list, ok := err.(parser.ErrorList)
+		if !ok {
+			t.Errorf("Case %v, wrong error type. Expected \"ErrorList\", received \"%T\"", i, err)
+			continue
+		}
 
-			if cmd != test.commands[j].cmdType {
-				t.Errorf("Case %v, byte %v, command mismatch. Received \"%v\", expected \"%v\"", i, j, cmd, test.commands[j].cmdType)
-			}
+		if len(list) != len(test.expected) {
+			t.Errorf("Case %v, mismatched size. Received \"%v\", expected \"%v\"", i, len(list), len(test.expected))
+			continue
+		}
 
-			if qty != test.commands[j].cmdQty {
-				t.Errorf("Case %v, byte %v, qty mismatch. Received \"%v\", expected \"%v\"", i, j, qty, test.commands[j].cmdQty)
+		for j, e := range list {
+			var bracketErr parser.UnmatchedBracketError
+
+			if !errors.As(e, &bracketErr) {
+				t.Errorf("Case %v, error %v, wrong error type \"%T\"", i, j, e)
+			} else if bracketErr != test.expected[j] {
+				t.Errorf("Case %v, error %v, received \"%v\", expected \"%v\"", i, j, bracketErr, test.expected[j])
 			}
 		}
 	}
 }
+
+func TestParseBracketErrorMessage(t *testing.T) {
+	_, err := parser.Parse(strings.NewReader("[\n]]"))
+	expected := "2:2: unmatched ']'"
+
+	if err == nil || err.Error() != expected {
+		t.Errorf("Received \"%v\", expected \"%v\"", err, expected)
+	}
+}
+
+func TestParseSpanOffsets(t *testing.T) {
+	program, err := parser.Parse(strings.NewReader("ç+\n ++ "))
+
+	if err != nil || len(program) != 1 {
+		t.Fatalf("Unexpected result: \"%v\", \"%v\"", program, err)
+	}
+
+	expected := parser.Span{
+		Start: parser.Position{Offset: 2, Line: 1, Column: 2},
+		End:   parser.Position{Offset: 7, Line: 2, Column: 4},
+	}
+
+	if program[0].Span != expected {
+		t.Errorf("Wrong span. Received \"%#v\", expected \"%#v\"", program[0].Span, expected)
+	}
+}
@@ -1,20 +1,45 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/ibraimgm/bfi/codegen"
+	"github.com/ibraimgm/bfi/codegen/bundle"
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
 	"github.com/ibraimgm/bfi/vm"
 
 	getopt "github.com/pborman/getopt/v2"
 )
 
 func main() {
+	command, lang, args := parseCommand(os.Args)
+
 	tsFlag := getopt.UintLong("tapesize", 't', 3000, "sets the tape size")
 	csFlag := getopt.IntLong("cellsize", 'c', 8, "sets the cell size")
+	optFlag := getopt.IntLong("optimize", 'O', optimizer.MaxLevel, "sets the optimization level (0 to 3)")
+	disableFlag := getopt.ListLong("disable-pass", 0, "disables an optimizer pass", "name")
+	dumpFlag := getopt.ListLong("dump-ir", 0, "prints the program to stderr before or after a pass", "after:pass")
+	eofFlag := getopt.StringLong("eof", 0, "error", "sets what happens on end of input: error, unchanged, zero or minus-one")
+	tapeFlag := getopt.StringLong("tape", 0, "wrap", "sets what happens past the tape ends: wrap, error, grow or infinite")
+	overflowFlag := getopt.StringLong("overflow", 0, "wrap", "sets what happens when a cell overflows: wrap, saturate or error")
+	signedFlag := getopt.BoolLong("signed", 0, "treats cells as signed numbers")
+	inFlag := getopt.StringLong("input", 0, "byte", "sets how input is read: byte, utf8 or decimal")
+	outFlag := getopt.StringLong("output", 0, "utf8", "sets how cells are written: utf8, byte, utf16le or decimal")
+	delimFlag := getopt.StringLong("delimiter", 0, " ", "sets the text written after each number in decimal output")
+	backendFlag := getopt.StringLong("backend", 0, "bytecode", "sets how the code is executed: bytecode, closure or jit")
+	outputFlag := getopt.StringLong("out", 'o', "", "sets the output file of emit and build (default: standard output)", "file")
+	packageFlag := getopt.StringLong("package", 0, "main", "sets the package name of the Go code from emit go", "name")
+	watFlag := getopt.StringLong("wat", 0, "", "also writes the text form of the module from emit wasm", "file")
+	nativeFlag := getopt.BoolLong("native", 0, "makes build write a static linux/amd64 executable directly")
+	moduleFlag := getopt.StringLong("module", 0, bundle.DefaultOptions().Module, "sets the bfi version, or a local copy of it, used by build", "version|dir")
 	helpFlag := getopt.BoolLong("help", 'h', "prints this help message")
 
-	if err := getopt.Getopt(nil); err != nil {
+	getopt.SetParameters("[emit <lang> | build] file")
+
+	if err := getopt.CommandLine.Getopt(args, nil); err != nil {
 		fmt.Printf("%v\n\n", err)
 		getopt.Usage()
 		os.Exit(1)
@@ -31,18 +56,122 @@ func main() {
 		os.Exit(1)
 	}
 
-	args := getopt.Args()
+	pipeline, err := newPipeline(*optFlag, *disableFlag, *dumpFlag)
+	if err != nil {
+		fmt.Printf("%v\n\n", err)
+		getopt.Usage()
+		os.Exit(1)
+	}
+
+	eofMode, err := vm.ParseEOFMode(*eofFlag)
+	if err != nil {
+		fmt.Printf("%v\n\n", err)
+		getopt.Usage()
+		os.Exit(1)
+	}
+
+	tapeMode, err := vm.ParseTapeMode(*tapeFlag)
+	if err != nil {
+		fmt.Printf("%v\n\n", err)
+		getopt.Usage()
+		os.Exit(1)
+	}
+
+	overflowMode, err := vm.ParseOverflowMode(*overflowFlag)
+	if err != nil {
+		fmt.Printf("%v\n\n", err)
+		getopt.Usage()
+		os.Exit(1)
+	}
+
+	inMode, err := vm.ParseInputMode(*inFlag)
+	if err != nil {
+		fmt.Printf("%v\n\n", err)
+		getopt.Usage()
+		os.Exit(1)
+	}
+
+	outMode, err := vm.ParseOutputMode(*outFlag)
+	if err != nil {
+		fmt.Printf("%v\n\n", err)
+		getopt.Usage()
+		os.Exit(1)
+	}
+
+	backend, err := vm.ParseBackend(*backendFlag)
+	if err != nil {
+		fmt.Printf("%v\n\n", err)
+		getopt.Usage()
+		os.Exit(1)
+	}
+
+	args = getopt.Args()
 	if len(args) != 1 {
 		fmt.Printf("missing file argument\n\n")
 		getopt.Usage()
 		os.Exit(1)
 	}
 
+	if command == "emit" {
+		opts := emitOptions{
+			Options: codegen.Options{CellSize: *csFlag, TapeSize: int(*tsFlag), EOFMode: eofMode},
+			Package: *packageFlag,
+			Output:  *outputFlag,
+			Wat:     *watFlag,
+		}
+
+		if err := emit(lang, args[0], pipeline, opts); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if command == "build" {
+		opts := buildOptions{
+			Options: bundle.Options{
+				CellSize:     *csFlag,
+				TapeSize:     int(*tsFlag),
+				EOFMode:      eofMode,
+				TapeMode:     tapeMode,
+				OverflowMode: overflowMode,
+				Signed:       *signedFlag,
+				InputMode:    inMode,
+				OutputMode:   outMode,
+				Delimiter:    *delimFlag,
+				Backend:      backend,
+				Level:        *optFlag,
+				Disabled:     *disableFlag,
+				Module:       *moduleFlag,
+			},
+			Output: *outputFlag,
+			Native: *nativeFlag,
+		}
+
+		if err := build(args[0], pipeline, opts); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	bfvm, err := vm.WithSpecs(*csFlag, int(*tsFlag))
 	if err != nil {
 		fmt.Printf("error creating vm: %v", err)
 	}
 
+	bfvm.SetPipeline(pipeline)
+	bfvm.SetEOFMode(eofMode)
+	bfvm.SetTapeMode(tapeMode)
+	bfvm.SetOverflowMode(overflowMode)
+	bfvm.SetSigned(*signedFlag)
+	bfvm.SetInputMode(inMode)
+	bfvm.SetOutputMode(outMode)
+	bfvm.SetOutputDelimiter(*delimFlag)
+	bfvm.SetBackend(backend)
+
 	file, err := os.Open(args[0])
 	if err != nil {
 		fmt.Printf("error opening %s: %v", args[0], err)
@@ -50,12 +179,66 @@ func main() {
 	}
 
 	if err := bfvm.LoadFromStream(file); err != nil {
-		fmt.Printf("error loading source file: %v", err)
+		fmt.Printf("error loading source file:\n%v\n", err)
 		os.Exit(1)
 	}
 
 	if err := bfvm.Run(); err != nil {
-		fmt.Printf("error running virtual machine: %v", err)
+		printRuntimeError(args[0], err)
 		os.Exit(1)
 	}
 }
+
+// parseCommand splits the command line in the command ("run" when none is
+// given), the language of emit and the arguments left for getopt
+func parseCommand(args []string) (string, string, []string) {
+	if len(args) > 2 && args[1] == "emit" {
+		return "emit", args[2], append([]string{args[0]}, args[3:]...)
+	}
+
+	if len(args) > 1 && args[1] == "build" {
+		return "build", "", append([]string{args[0]}, args[2:]...)
+	}
+
+	return "run", "", args
+}
+
+func printRuntimeError(filename string, err error) {
+	var runtimeErr *vm.RuntimeError
+
+	if !errors.As(err, &runtimeErr) {
+		fmt.Printf("error running virtual machine: %v\n", err)
+		return
+	}
+
+	if errors.Is(err, io.EOF) {
+		fmt.Printf("\n%s:%v: ran out of input\n", filename, runtimeErr.Span.Start)
+	} else {
+		fmt.Printf("\n%s:%v: %v\n", filename, runtimeErr.Span.Start, runtimeErr.Err)
+	}
+
+	fmt.Printf("  source %v, instruction %d, pointer %d, cell value %d, after %d steps\n",
+		runtimeErr.Span, runtimeErr.IP, runtimeErr.Pointer, runtimeErr.Value, runtimeErr.Steps)
+}
+
+func newPipeline(level int, disabled []string, dumps []string) (*optimizer.Pipeline, error) {
+	pipeline, err := optimizer.New(level)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range disabled {
+		if err := pipeline.Disable(name); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, spec := range dumps {
+		if err := pipeline.DumpAt(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	pipeline.SetDumpOutput(os.Stderr)
+	return pipeline, nil
+}
@@ -0,0 +1,66 @@
+package vm
+
+import (
+	"math"
+)
+
+// add adds delta to the cell value, applying the overflow mode. The result
+// is only meaningful up to the cell size.
+func (vm *BFVM) add(value uint64, delta int) (uint64, error) {
+	if vm.overflow == OverflowWrap {
+		return value + uint64(delta), nil
+	}
+
+	var bound uint64
+	var overflow, underflow bool
+
+	if vm.signed {
+		min, max := vm.signedRange()
+		value := vm.toSigned(value)
+		overflow = delta > 0 && value > max-int64(delta)
+		underflow = delta < 0 && value < min-int64(delta)
+
+		if overflow {
+			bound = uint64(max)
+		} else {
+			bound = uint64(min)
+		}
+	} else {
+		max := vm.unsignedMax()
+		value &= max
+		overflow = delta > 0 && uint64(delta) > max-value
+		underflow = delta < 0 && uint64(-delta) > value
+
+		if overflow {
+			bound = max
+		}
+	}
+
+	switch {
+	case !overflow && !underflow:
+		return value + uint64(delta), nil
+
+	case vm.overflow == OverflowSaturate:
+		return bound, nil
+
+	default:
+		return 0, CellOverflowError{Underflow: underflow}
+	}
+}
+
+// toSigned returns the cell value as a signed (two's complement) number
+func (vm *BFVM) toSigned(value uint64) int64 {
+	shift := uint(64 - vm.cellSize)
+	return int64(value<<shift) >> shift
+}
+
+// unsignedMax returns the maximum unsigned value of a cell
+func (vm *BFVM) unsignedMax() uint64 {
+	return math.MaxUint64 >> uint(64-vm.cellSize)
+}
+
+// signedRange returns the minimum and maximum signed values of a cell
+func (vm *BFVM) signedRange() (int64, int64) {
+	max := int64(math.MaxInt64 >> uint(64-vm.cellSize))
+	return -max - 1, max
+}
@@ -0,0 +1,114 @@
+package vm_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/vm"
+)
+
+func TestParseOverflowMode(t *testing.T) {
+	for _, mode := range []vm.OverflowMode{vm.OverflowWrap, vm.OverflowSaturate, vm.OverflowError} {
+		parsed, err := vm.ParseOverflowMode(mode.String())
+
+		if err != nil || parsed != mode {
+			t.Errorf("Mode \"%v\", received \"%v\", \"%v\"", mode, parsed, err)
+		}
+	}
+
+	if _, err := vm.ParseOverflowMode("nope"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+}
+
+func TestOverflowMode(t *testing.T) {
+	testCases := []struct {
+		mode     vm.OverflowMode
+		signed   bool
+		size     int
+		source   string
+		expected string
+	}{
+		{mode: vm.OverflowWrap, size: 8, source: `-.` + strings.Repeat("+", 3) + `.`, expected: "255 2 "},
+		{mode: vm.OverflowWrap, signed: true, size: 8, source: `-.` + strings.Repeat("+", 129) + `.`, expected: "-1 -128 "},
+		{mode: vm.OverflowSaturate, size: 8, source: `--.` + strings.Repeat("+", 300) + `.`, expected: "0 255 "},
+		{mode: vm.OverflowSaturate, signed: true, size: 8, source: strings.Repeat("-", 200) + `.` + strings.Repeat("+", 300) + `.`, expected: "-128 127 "},
+		{mode: vm.OverflowSaturate, size: 16, source: strings.Repeat("+", 300) + `.[-].`, expected: "300 0 "},
+		{mode: vm.OverflowSaturate, size: 64, source: `-.+.`, expected: "0 1 "},
+		{mode: vm.OverflowSaturate, signed: true, size: 64, source: `-.+.`, expected: "-1 0 "},
+		{mode: vm.OverflowError, size: 8, source: `+++[-]>++[->+++<]>.`, expected: "6 "},
+	}
+
+	for i, test := range testCases {
+		machine, err := vm.WithCellSize(test.size)
+
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		machine.SetOverflowMode(test.mode)
+		machine.SetSigned(test.signed)
+		machine.SetOutputMode(vm.OutputDecimal)
+
+		if err = machine.LoadFromString(test.source); err != nil {
+			t.Fatalf("Case %v, unexpected error: %v", i, err)
+		}
+
+		writer := strings.Builder{}
+		machine.SetIO(strings.NewReader(""), &writer)
+
+		if err = machine.Run(); err != nil {
+			t.Errorf("Case %v, unexpected error: %v", i, err)
+		}
+
+		if writer.String() != test.expected {
+			t.Errorf("Case %v, expected output to be \"%v\", but it was \"%v\".", i, test.expected, writer.String())
+		}
+	}
+}
+
+func TestOverflowModeErrors(t *testing.T) {
+	testCases := []struct {
+		signed    bool
+		size      int
+		source    string
+		pos       string
+		underflow bool
+	}{
+		{size: 8, source: "+\n-\n-", pos: "2:1-3:2", underflow: true},
+		{size: 8, source: strings.Repeat("+", 256), pos: "1:1-1:257"},
+		{size: 8, source: "+[+]", pos: "1:3-1:4"},
+		{signed: true, size: 8, source: strings.Repeat("+", 127) + " +", pos: "1:1-1:130"},
+		{signed: true, size: 16, source: "-[-]", pos: "1:3-1:4", underflow: true},
+		{size: 64, source: "-", pos: "1:1-1:2", underflow: true},
+	}
+
+	for i, test := range testCases {
+		machine, _ := vm.WithCellSize(test.size)
+		machine.SetOverflowMode(vm.OverflowError)
+		machine.SetSigned(test.signed)
+
+		if err := machine.LoadFromString(test.source); err != nil {
+			t.Fatalf("Case %v, unexpected error: %v", i, err)
+		}
+
+		err := machine.Run()
+
+		var runtimeErr *vm.RuntimeError
+		var overflowErr vm.CellOverflowError
+
+		if !errors.As(err, &runtimeErr) || !errors.As(err, &overflowErr) {
+			t.Errorf("Case %v, expected a CellOverflowError, received \"%v\"", i, err)
+			continue
+		}
+
+		if runtimeErr.Span.String() != test.pos {
+			t.Errorf("Case %v, wrong position. Expected \"%v\", received \"%v\"", i, test.pos, runtimeErr.Span)
+		}
+
+		if overflowErr.Underflow != test.underflow {
+			t.Errorf("Case %v, wrong error \"%v\"", i, overflowErr)
+		}
+	}
+}
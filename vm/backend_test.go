@@ -0,0 +1,205 @@
+package vm_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// backendEnv selects the default backend of the machines created by the
+// tests. It is set when the test binary runs itself for every backend.
+const backendEnv = "BFI_TEST_BACKEND"
+
+var backends = []vm.Backend{vm.BackendBytecode, vm.BackendClosure}
+
+func init() {
+	if vm.JITSupported {
+		backends = append(backends, vm.BackendJIT)
+	}
+}
+
+func TestMain(m *testing.M) {
+	if name := os.Getenv(backendEnv); name != "" {
+		backend, err := vm.ParseBackend(name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		vm.SetDefaultBackend(backend)
+	}
+
+	os.Exit(m.Run())
+}
+
+// TestEveryBackend runs the whole test suite again for each backend other
+// than the default one
+func TestEveryBackend(t *testing.T) {
+	if os.Getenv(backendEnv) != "" || testing.Short() {
+		t.Skip("already running with a backend")
+	}
+
+	for _, backend := range backends[1:] {
+		cmd := exec.Command(os.Args[0], "-test.run", "^Test", "-test.count", "1")
+		cmd.Env = append(os.Environ(), backendEnv+"="+backend.String())
+
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("%v backend, test suite failed: %v\n%s", backend, err, output)
+		}
+	}
+}
+
+type backendCase struct {
+	source   string
+	input    string
+	cellSize int
+	tapeSize int
+	tapeMode vm.TapeMode
+	overflow vm.OverflowMode
+	signed   bool
+	eofMode  vm.EOFMode
+	outMode  vm.OutputMode
+}
+
+type backendResult struct {
+	output string
+	err    string
+	tape   []uint64
+	origin int
+}
+
+func runBackend(t *testing.T, test backendCase, backend vm.Backend) backendResult {
+	cellSize, tapeSize := test.cellSize, test.tapeSize
+
+	if cellSize == 0 {
+		cellSize = 8
+	}
+
+	if tapeSize == 0 {
+		tapeSize = 3000
+	}
+
+	machine, err := vm.WithSpecs(cellSize, tapeSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	machine.SetBackend(backend)
+	machine.SetTapeMode(test.tapeMode)
+	machine.SetOverflowMode(test.overflow)
+	machine.SetSigned(test.signed)
+	machine.SetEOFMode(test.eofMode)
+	machine.SetOutputMode(test.outMode)
+
+	if err := machine.LoadFromString(test.source); err != nil {
+		t.Fatal(err)
+	}
+
+	writer := strings.Builder{}
+	machine.SetIO(strings.NewReader(test.input), &writer)
+
+	var result backendResult
+	if err := machine.Run(); err != nil {
+		result.err = err.Error()
+	}
+
+	result.output = writer.String()
+	result.origin = machine.Origin()
+
+	for _, cell := range machine.GetTapeState() {
+		result.tape = append(result.tape, cell.ToUint64())
+	}
+
+	return result
+}
+
+func TestBackendsMatchBytecode(t *testing.T) {
+	testCases := []backendCase{
+		{source: "++++++++[>++++[>++>+++>+++>+<<<<-]>+>+>->>+[<]<-]>>.>---.+++++++..+++.>>.<-.<.+++.------.--------.>>+.>++."},
+		{source: "+[-->-[>>+>-----<<]<--<---]>-.>>>+.>>..+++[.>]<<<<.+++.------.<<-.>>>>+."},
+		{source: ">>>>++++++++++[->++++++++++[-<<<+<+<+>>>>>]<]<<+++++<++<--[.>]"},
+		{source: "+>+>+>+>>+<<<<<[>]+>>>>>>+>+>>+<<[>>]>+[<<<]+"},
+		{source: "++++[->+++<]>[->++>+++>-<<<]>>>>[-<<<<+>>>>]<<[-<+>]"},
+		{source: ",[.,]", input: "echo"},
+		{source: ",[.,]", input: "ab", eofMode: vm.EOFZero},
+		{source: ",.,.,.", input: "a", eofMode: vm.EOFMinusOne, outMode: vm.OutputDecimal},
+		{source: ",[.,]", input: "ab"},
+		{source: "++++[>+++<-]>.", cellSize: 16, outMode: vm.OutputDecimal},
+		{source: "-.>-[-<->]<.", cellSize: 32, outMode: vm.OutputDecimal},
+		{source: "-[>-<-]>.", cellSize: 16, outMode: vm.OutputDecimal},
+		{source: "--[-->+<]>.", cellSize: 16, outMode: vm.OutputDecimal},
+		{source: "->->->-[<]>+", cellSize: 64, tapeSize: 8},
+		{source: "<<+>>>>+[<]", tapeSize: 4},
+		{source: ">>>+<<<<", tapeSize: 4, tapeMode: vm.TapeError},
+		{source: "+[>+]", tapeSize: 4, tapeMode: vm.TapeError},
+		{source: "++[>>>>>>+<<<<<<-]>>>>>>.", tapeSize: 4, tapeMode: vm.TapeGrow},
+		{source: "+>>>>>>+[<]", tapeSize: 4, tapeMode: vm.TapeGrow},
+		{source: "+<<<+>>>>>>>+<<[<]<<", tapeSize: 4, tapeMode: vm.TapeInfinite},
+		{source: "++++[->++<]<<<<+[>-<-]", tapeSize: 4, tapeMode: vm.TapeInfinite},
+		{source: "-[-]-", overflow: vm.OverflowSaturate},
+		{source: "+[+]", overflow: vm.OverflowError},
+		{source: "-", overflow: vm.OverflowError, signed: true, cellSize: 16},
+		{source: "+++++++[>++++++++++++++++++++<-]>.", overflow: vm.OverflowSaturate, signed: true, outMode: vm.OutputDecimal},
+	}
+
+	for i, test := range testCases {
+		expected := runBackend(t, test, vm.BackendBytecode)
+
+		for _, backend := range backends[1:] {
+			result := runBackend(t, test, backend)
+
+			if result.output != expected.output {
+				t.Errorf("Case %v, %v backend, output mismatch. Expected \"%v\", received \"%v\"", i, backend, expected.output, result.output)
+			}
+
+			if result.err != expected.err {
+				t.Errorf("Case %v, %v backend, error mismatch. Expected \"%v\", received \"%v\"", i, backend, expected.err, result.err)
+			}
+
+			if result.origin != expected.origin {
+				t.Errorf("Case %v, %v backend, origin mismatch. Expected %v, received %v", i, backend, expected.origin, result.origin)
+			}
+
+			if len(result.tape) != len(expected.tape) {
+				t.Errorf("Case %v, %v backend, tape size mismatch. Expected %v, received %v", i, backend, len(expected.tape), len(result.tape))
+				continue
+			}
+
+			for j := range expected.tape {
+				if result.tape[j] != expected.tape[j] {
+					t.Errorf("Case %v, %v backend, cell %v, value mismatch. Expected %v, received %v", i, backend, j, expected.tape[j], result.tape[j])
+				}
+			}
+		}
+	}
+}
+
+func TestBackendRunsTwice(t *testing.T) {
+	for _, backend := range backends {
+		machine, _ := vm.New()
+		machine.SetBackend(backend)
+
+		if err := machine.LoadFromString("+++[>++<-]>."); err != nil {
+			t.Fatal(err)
+		}
+
+		writer := strings.Builder{}
+		machine.SetIO(strings.NewReader(""), &writer)
+
+		for i := 0; i < 2; i++ {
+			machine.Reset()
+
+			if err := machine.Run(); err != nil {
+				t.Errorf("%v backend, run %v, unexpected error: %v", backend, i, err)
+			}
+		}
+
+		if writer.String() != "\x06\x06" {
+			t.Errorf("%v backend, output mismatch. Expected \"\\x06\\x06\", received %q", backend, writer.String())
+		}
+	}
+}
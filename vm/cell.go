@@ -18,20 +18,23 @@ func CheckCellSize(size int) error {
 // Cell represents a cell in the tape. A cell might have 8, 16, 32 or 64
 // bits (this is defined in the tape creation).
 //
-// Be wary that by using the ToUint* methods, you must take care to ensure
-// you are using the correct integer type.
+// Values given to Add and Subtract wrap around the cell size. Be wary that
+// by using the ToUint* methods, you must take care to ensure you are using
+// the correct integer type. ToInt64 returns the value as a signed
+// (two's complement) number of the cell size.
 type Cell interface {
 	fmt.Stringer
 	Inc()
-	Add(value byte)
+	Add(value uint64)
 	Dec()
-	Subtract(value byte)
+	Subtract(value uint64)
 	Zero()
 	IsZero() bool
 	ToUint8() uint8
 	ToUint16() uint16
 	ToUint32() uint32
 	ToUint64() uint64
+	ToInt64() int64
 	Clone() Cell
 }
 
@@ -43,16 +46,16 @@ func (c *cellImpl) Inc() {
 	c.Add(1)
 }
 
-func (c *cellImpl) Add(value byte) {
+func (c *cellImpl) Add(value uint64) {
 	switch c.inner.(type) {
 	case uint8:
-		c.inner = c.inner.(uint8) + value
+		c.inner = c.inner.(uint8) + uint8(value)
 	case uint16:
 		c.inner = c.inner.(uint16) + uint16(value)
 	case uint32:
 		c.inner = c.inner.(uint32) + uint32(value)
 	case uint64:
-		c.inner = c.inner.(uint64) + uint64(value)
+		c.inner = c.inner.(uint64) + value
 	}
 }
 
@@ -60,16 +63,16 @@ func (c *cellImpl) Dec() {
 	c.Subtract(1)
 }
 
-func (c *cellImpl) Subtract(value byte) {
+func (c *cellImpl) Subtract(value uint64) {
 	switch c.inner.(type) {
 	case uint8:
-		c.inner = c.inner.(uint8) - value
+		c.inner = c.inner.(uint8) - uint8(value)
 	case uint16:
 		c.inner = c.inner.(uint16) - uint16(value)
 	case uint32:
 		c.inner = c.inner.(uint32) - uint32(value)
 	case uint64:
-		c.inner = c.inner.(uint64) - uint64(value)
+		c.inner = c.inner.(uint64) - value
 	}
 }
 
@@ -130,6 +133,19 @@ func (c *cellImpl) ToUint64() uint64 {
 	}
 }
 
+func (c *cellImpl) ToInt64() int64 {
+	switch c.inner.(type) {
+	case uint8:
+		return int64(int8(c.inner.(uint8)))
+	case uint16:
+		return int64(int16(c.inner.(uint16)))
+	case uint32:
+		return int64(int32(c.inner.(uint32)))
+	default:
+		return int64(c.inner.(uint64))
+	}
+}
+
 func (c *cellImpl) Clone() Cell {
 	other := *c
 	return &other
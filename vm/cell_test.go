@@ -96,3 +96,51 @@ func TestNewCellError(t *testing.T) {
 		t.Errorf("Wrong error message. Received \"%v\"", e2.Error())
 	}
 }
+
+func TestCellWideValues(t *testing.T) {
+	testCases := []struct {
+		size     int
+		add, sub uint64
+		expected uint64
+	}{
+		{size: 8, add: 300, expected: 44},
+		{size: 8, sub: 300, expected: 212},
+		{size: 16, add: 70000, expected: 4464},
+		{size: 32, add: 70000, sub: 1, expected: 69999},
+		{size: 64, sub: 1, expected: math.MaxUint64},
+	}
+
+	for i, test := range testCases {
+		c, _ := newCell(test.size)
+		c.Add(test.add)
+		c.Subtract(test.sub)
+
+		if c.ToUint64() != test.expected {
+			t.Errorf("Case %v, expected cell value to be %v, received \"%v\"", i, test.expected, c.ToUint64())
+		}
+	}
+}
+
+func TestCellSigned(t *testing.T) {
+	testCases := []struct {
+		size     int
+		sub      uint64
+		expected int64
+	}{
+		{size: 8, sub: 1, expected: -1},
+		{size: 8, sub: 128, expected: -128},
+		{size: 8, sub: 129, expected: 127},
+		{size: 16, sub: 2, expected: -2},
+		{size: 32, sub: 1 << 31, expected: math.MinInt32},
+		{size: 64, sub: 5, expected: -5},
+	}
+
+	for i, test := range testCases {
+		c, _ := newCell(test.size)
+		c.Subtract(test.sub)
+
+		if c.ToInt64() != test.expected {
+			t.Errorf("Case %v, expected signed value to be %v, received \"%v\"", i, test.expected, c.ToInt64())
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package vm
+
+import (
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+// closureState is the state shared by the closures of a compiled program.
+// As in the bytecode loop, the pointer is only written back to vm.position
+// when something else needs it.
+type closureState[T cellType] struct {
+	vm    *BFVM
+	tape  *typedTape[T]
+	cells []T
+	pos   int
+	steps uint64
+}
+
+// step is a single compiled instruction. Loops are a single step, which runs
+// the steps of their body.
+type step[T cellType] func(s *closureState[T]) error
+
+// locate returns the tape index of the position, applying the tape mode
+// when it falls outside of the tape
+func (s *closureState[T]) locate(position int) (int, error) {
+	if uint(position) < uint(len(s.cells)) {
+		return position, nil
+	}
+
+	s.vm.position = s.pos
+	index, err := s.tape.locate(s.vm, position)
+	s.cells = s.tape.cells
+	s.pos = s.vm.position
+	return index, err
+}
+
+func (s *closureState[T]) fail(ip int, err error) error {
+	s.vm.position = s.pos
+	return s.vm.runtimeError(ip, s.steps, err)
+}
+
+func runSteps[T cellType](s *closureState[T], steps []step[T]) error {
+	for _, st := range steps {
+		if err := st(s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runClosures compiles the loaded code into closures, if needed, and runs them
+func (t *typedTape[T]) runClosures(vm *BFVM) error {
+	program, ok := vm.compiled.([]step[T])
+	if !ok {
+		program = compileSteps[T](vm, vm.ops, 0, len(vm.ops))
+		vm.compiled = program
+	}
+
+	s := &closureState[T]{vm: vm, tape: t, cells: t.cells, pos: vm.position}
+	err := runSteps(s, program)
+
+	if err == nil {
+		vm.position = s.pos
+	}
+
+	return err
+}
+
+// compileSteps compiles the ops from start (inclusive) to end (exclusive).
+// The jumps must be already linked.
+func compileSteps[T cellType](vm *BFVM, ops []op, start int, end int) []step[T] {
+	steps := make([]step[T], 0, end-start)
+
+	for ip := start; ip < end; ip++ {
+		ins := ops[ip]
+
+		if ins.cmd == parser.CmdJump {
+			steps = append(steps, compileLoop[T](vm, ops, ip))
+			ip = ins.arg
+			continue
+		}
+
+		steps = append(steps, compileStep[T](vm, ins, ip))
+	}
+
+	return steps
+}
+
+// compileLoop compiles the loop starting at the jump instruction in ip.
+// The jump and the return are still counted as steps, like in the bytecode
+// loop, where every iteration goes back to the jump.
+func compileLoop[T cellType](vm *BFVM, ops []op, ip int) step[T] {
+	body := compileSteps[T](vm, ops, ip+1, ops[ip].arg)
+
+	return func(s *closureState[T]) error {
+		s.steps++
+
+		for s.cells[s.pos] != 0 {
+			if err := runSteps(s, body); err != nil {
+				return err
+			}
+
+			s.steps++
+
+			if s.cells[s.pos] == 0 {
+				break
+			}
+
+			s.steps++
+		}
+
+		return nil
+	}
+}
+
+// compileStep compiles a single instruction, other than a jump or a return
+func compileStep[T cellType](vm *BFVM, ins op, ip int) step[T] {
+	arg, offset := ins.arg, ins.offset
+
+	switch ins.cmd {
+	case parser.CmdMove:
+		return func(s *closureState[T]) error {
+			s.steps++
+			index, err := s.locate(s.pos + arg)
+			if err != nil {
+				return s.fail(ip, err)
+			}
+
+			s.pos = index
+			return nil
+		}
+
+	case parser.CmdAdd:
+		if vm.overflow == OverflowWrap && offset == 0 {
+			delta := T(arg)
+
+			return func(s *closureState[T]) error {
+				s.steps++
+				s.cells[s.pos] += delta
+				return nil
+			}
+		}
+
+		return func(s *closureState[T]) error {
+			s.steps++
+			target, err := s.locate(s.pos + offset)
+			if err != nil {
+				return s.fail(ip, err)
+			}
+
+			value, err := s.vm.add(uint64(s.cells[target]), arg)
+			if err != nil {
+				return s.fail(ip, err)
+			}
+
+			s.cells[target] = T(value)
+			return nil
+		}
+
+	case parser.CmdClear:
+		return func(s *closureState[T]) error {
+			s.steps++
+			target, err := s.locate(s.pos + offset)
+			if err != nil {
+				return s.fail(ip, err)
+			}
+
+			s.cells[target] = 0
+			return nil
+		}
+
+	case parser.CmdMulAdd:
+		factor := T(arg)
+
+		return func(s *closureState[T]) error {
+			s.steps++
+			target, err := s.locate(s.pos + offset)
+			if err != nil {
+				return s.fail(ip, err)
+			}
+
+			s.cells[target] += factor * s.cells[s.pos]
+			return nil
+		}
+
+	case parser.CmdScan:
+		return func(s *closureState[T]) error {
+			s.steps++
+
+			if s.cells[s.pos] != 0 {
+				s.pos, _ = scanBytes(s.cells, s.pos, arg)
+			}
+
+			for s.cells[s.pos] != 0 {
+				index, err := s.locate(s.pos + arg)
+				if err != nil {
+					return s.fail(ip, err)
+				}
+
+				s.pos = index
+			}
+
+			return nil
+		}
+
+	case parser.CmdInput:
+		return func(s *closureState[T]) error {
+			s.steps++
+			value, err := s.vm.input(uint64(s.cells[s.pos]))
+			if err != nil {
+				return s.fail(ip, err)
+			}
+
+			s.cells[s.pos] = T(value)
+			return nil
+		}
+
+	default:
+		return func(s *closureState[T]) error {
+			s.steps++
+			if err := s.vm.output(uint64(s.cells[s.pos])); err != nil {
+				return s.fail(ip, err)
+			}
+
+			return nil
+		}
+	}
+}
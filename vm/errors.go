@@ -2,6 +2,8 @@ package vm
 
 import (
 	"fmt"
+
+	"github.com/ibraimgm/bfi/interpreter/parser"
 )
 
 // InvalidCellSizeError indicates that a wrong cell size was specified.
@@ -10,3 +12,83 @@ type InvalidCellSizeError int
 func (err InvalidCellSizeError) Error() string {
 	return fmt.Sprintf("invalid cell size: %v", int(err))
 }
+
+// InvalidModeError indicates that an unknown mode name was specified.
+type InvalidModeError struct {
+	Kind string
+	Name string
+}
+
+func (err InvalidModeError) Error() string {
+	return fmt.Sprintf("invalid %v mode: %v", err.Kind, err.Name)
+}
+
+// UnsupportedBackendError indicates that the backend can not run on
+// this platform.
+type UnsupportedBackendError Backend
+
+func (err UnsupportedBackendError) Error() string {
+	return fmt.Sprintf("the %v backend is not supported on this platform", Backend(err))
+}
+
+// InvalidNumberError indicates that the input does not hold a valid number,
+// when using InputDecimal.
+type InvalidNumberError string
+
+func (err InvalidNumberError) Error() string {
+	return fmt.Sprintf("invalid number: %q", string(err))
+}
+
+// OutOfBoundsError indicates that the pointer moved past the ends of the tape.
+// The value is the position, relative to the initial cell.
+type OutOfBoundsError int
+
+func (err OutOfBoundsError) Error() string {
+	return fmt.Sprintf("pointer moved outside of the tape (position %d)", int(err))
+}
+
+// CellOverflowError indicates that a cell value moved past its maximum
+// value or, when Underflow is true, its minimum value.
+type CellOverflowError struct {
+	Underflow bool
+}
+
+func (err CellOverflowError) Error() string {
+	if err.Underflow {
+		return "cell underflow"
+	}
+
+	return "cell overflow"
+}
+
+// RuntimeError indicates a failure while running a program, along with the
+// state of the virtual machine when it happened. The original error is
+// available through errors.Is and errors.As.
+type RuntimeError struct {
+	// IP is the index of the failed instruction
+	IP int
+
+	// Span is the source code of the failed instruction
+	Span parser.Span
+
+	// Pointer is the index of the current cell, as returned by GetTapeState
+	Pointer int
+
+	// Value is the value of the cell under the pointer
+	Value uint64
+
+	// Steps is the number of instructions executed, including the failed one
+	Steps uint64
+
+	Err error
+}
+
+func (err *RuntimeError) Error() string {
+	return fmt.Sprintf("%v: %v (instruction %d, pointer %d, cell value %d, step %d)",
+		err.Span.Start, err.Err, err.IP, err.Pointer, err.Value, err.Steps)
+}
+
+// Unwrap returns the original error
+func (err *RuntimeError) Unwrap() error {
+	return err.Err
+}
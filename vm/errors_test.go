@@ -0,0 +1,84 @@
+package vm_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/vm"
+)
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestRuntimeError(t *testing.T) {
+	testCases := []struct {
+		source  string
+		inputs  string
+		writer  io.Writer
+		ip      int
+		pos     string
+		pointer int
+		value   uint64
+		steps   uint64
+		cause   error
+	}{
+		{source: ",.,", inputs: "A", ip: 2, pos: "1:3", pointer: 0, value: 65, steps: 3, cause: io.EOF},
+		{source: "+++\n>>++ ,", ip: 3, pos: "2:6", pointer: 2, value: 2, steps: 4, cause: io.EOF},
+		{source: "+[>+<-]>.", writer: failWriter{}, ip: 4, pos: "1:9", pointer: 1, value: 1, steps: 5},
+	}
+
+	for i, test := range testCases {
+		machine, err := vm.LoadFromString(test.source)
+
+		if err != nil {
+			t.Fatalf("Case %v, unexpected error: %v", i, err)
+		}
+
+		writer := test.writer
+		if writer == nil {
+			writer = &strings.Builder{}
+		}
+
+		machine.SetIO(strings.NewReader(test.inputs), writer)
+		err = machine.Run()
+
+		var runtimeErr *vm.RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("Case %v, wrong error type. Expected \"*RuntimeError\", received \"%T\"", i, err)
+			continue
+		}
+
+		if test.cause != nil && !errors.Is(err, test.cause) {
+			t.Errorf("Case %v, expected error to wrap \"%v\", received \"%v\"", i, test.cause, runtimeErr.Err)
+		}
+
+		if runtimeErr.IP != test.ip {
+			t.Errorf("Case %v, wrong IP. Expected \"%v\", received \"%v\"", i, test.ip, runtimeErr.IP)
+		}
+
+		if runtimeErr.Span.Start.String() != test.pos {
+			t.Errorf("Case %v, wrong position. Expected \"%v\", received \"%v\"", i, test.pos, runtimeErr.Span.Start)
+		}
+
+		if runtimeErr.Pointer != test.pointer {
+			t.Errorf("Case %v, wrong pointer. Expected \"%v\", received \"%v\"", i, test.pointer, runtimeErr.Pointer)
+		}
+
+		if runtimeErr.Value != test.value {
+			t.Errorf("Case %v, wrong value. Expected \"%v\", received \"%v\"", i, test.value, runtimeErr.Value)
+		}
+
+		if runtimeErr.Steps != test.steps {
+			t.Errorf("Case %v, wrong steps. Expected \"%v\", received \"%v\"", i, test.steps, runtimeErr.Steps)
+		}
+
+		if !strings.HasPrefix(err.Error(), test.pos+": ") {
+			t.Errorf("Case %v, wrong message \"%v\"", i, err.Error())
+		}
+	}
+}
@@ -0,0 +1,6 @@
+package vm
+
+// SetDefaultBackend sets the backend of the machines created afterwards
+func SetDefaultBackend(backend Backend) {
+	defaultBackend = backend
+}
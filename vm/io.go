@@ -0,0 +1,152 @@
+package vm
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// input reads a value for the cell, decoded according to the input mode.
+// When there is no more input, the current value is changed according to
+// the EOF mode.
+func (vm *BFVM) input(current uint64) (uint64, error) {
+	var value uint64
+	var err error
+
+	switch vm.inMode {
+	case InputUTF8:
+		var r rune
+		r, _, err = vm.stdin.ReadRune()
+		value = uint64(r)
+
+	case InputDecimal:
+		value, err = vm.readDecimal()
+
+	default:
+		var b byte
+		b, err = vm.stdin.ReadByte()
+		value = uint64(b)
+	}
+
+	if err == io.EOF && vm.eofMode != EOFError {
+		return vm.handleEOF(current), nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
+
+// readDecimal reads a whitespace-separated decimal integer. Negative numbers
+// are stored as their two's complement.
+func (vm *BFVM) readDecimal() (uint64, error) {
+	word := make([]byte, 0, 20)
+
+	for {
+		b, err := vm.stdin.ReadByte()
+
+		if err == io.EOF && len(word) > 0 {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+
+		if unicode.IsSpace(rune(b)) {
+			if len(word) > 0 {
+				break
+			}
+
+			continue
+		}
+
+		word = append(word, b)
+	}
+
+	if word[0] == '-' {
+		value, err := strconv.ParseInt(string(word), 10, 64)
+		if err != nil {
+			return 0, InvalidNumberError(word)
+		}
+
+		return uint64(value), nil
+	}
+
+	value, err := strconv.ParseUint(string(word), 10, 64)
+	if err != nil {
+		return 0, InvalidNumberError(word)
+	}
+
+	return value, nil
+}
+
+// output writes the cell value, encoded according to the output mode
+func (vm *BFVM) output(value uint64) error {
+	buf := vm.outBuf[:0]
+
+	switch vm.outMode {
+	case OutputByte:
+		buf = append(buf, uint8(value))
+
+	case OutputUTF16LE:
+		switch {
+		case value <= 0xFFFF:
+			buf = appendUint16LE(buf, uint16(value))
+		case value > utf8.MaxRune:
+			// a single replacement character, as in UTF-8
+			buf = appendUint16LE(buf, uint16(utf8.RuneError))
+		default:
+			r1, r2 := utf16.EncodeRune(rune(value))
+			buf = appendUint16LE(appendUint16LE(buf, uint16(r1)), uint16(r2))
+		}
+
+	case OutputDecimal:
+		if vm.signed {
+			buf = strconv.AppendInt(buf, vm.toSigned(value), 10)
+		} else {
+			buf = strconv.AppendUint(buf, value, 10)
+		}
+
+		buf = append(buf, vm.outDelim...)
+
+	default:
+		var tmp [utf8.UTFMax]byte
+		n := utf8.EncodeRune(tmp[:], toRune(value))
+		buf = append(buf, tmp[:n]...)
+	}
+
+	vm.outBuf = buf
+	_, err := vm.stdout.Write(buf)
+	return err
+}
+
+func appendUint16LE(buf []byte, value uint16) []byte {
+	var tmp [2]byte
+	binary.LittleEndian.PutUint16(tmp[:], value)
+	return append(buf, tmp[:]...)
+}
+
+// toRune converts the value to a rune, using the replacement character
+// for values outside of the unicode range
+func toRune(value uint64) rune {
+	if value > utf8.MaxRune {
+		return utf8.RuneError
+	}
+
+	return rune(value)
+}
+
+// handleEOF returns the new cell value according to the EOF mode
+func (vm *BFVM) handleEOF(current uint64) uint64 {
+	switch vm.eofMode {
+	case EOFZero:
+		return 0
+	case EOFMinusOne:
+		return math.MaxUint64
+	default:
+		return current
+	}
+}
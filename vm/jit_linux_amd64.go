@@ -0,0 +1,114 @@
+package vm
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"github.com/ibraimgm/bfi/codegen/amd64"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+// jitSupported is true when BackendJIT can run on this platform
+const jitSupported = true
+
+// jitProgram is the loaded code compiled to machine code, in executable memory.
+// The instructions the machine code leaves to the caller run as closures.
+type jitProgram[T cellType] struct {
+	mem      []byte
+	entry    func(*amd64.State)
+	entries  []int
+	fallback []step[T]
+}
+
+func compileJIT[T cellType](vm *BFVM) (*jitProgram[T], error) {
+	native, err := amd64.Compile(vm.commands, amd64.Options{CellSize: vm.cellSize, Wrapping: vm.overflow == OverflowWrap})
+	if err != nil {
+		return nil, err
+	}
+
+	mem, err := syscall.Mmap(-1, 0, len(native.Code), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return nil, err
+	}
+
+	copy(mem, native.Code)
+
+	if err := syscall.Mprotect(mem, syscall.PROT_READ|syscall.PROT_EXEC); err != nil {
+		_ = syscall.Munmap(mem)
+		return nil, err
+	}
+
+	program := &jitProgram[T]{mem: mem, entries: native.Entries, fallback: make([]step[T], len(vm.ops))}
+
+	// a func value points to a word holding the address of the code
+	code := new(uintptr)
+	*code = uintptr(unsafe.Pointer(&mem[0]))
+	program.entry = *(*func(*amd64.State))(unsafe.Pointer(&code))
+
+	for ip, ins := range vm.ops {
+		if ins.cmd != parser.CmdJump && ins.cmd != parser.CmdReturn {
+			program.fallback[ip] = compileStep[T](vm, ins, ip)
+		}
+	}
+
+	runtime.SetFinalizer(program, func(p *jitProgram[T]) {
+		_ = syscall.Munmap(p.mem)
+	})
+
+	return program, nil
+}
+
+// runJIT compiles the loaded code to machine code, if needed, and runs it.
+// The machine code returns whenever it needs to run an instruction in Go,
+// such as I/O or moving past the ends of the tape, and is resumed afterwards.
+//
+// While the machine code runs, the goroutine can not be preempted. It also
+// returns at the jump of a loop every amd64.YieldInterval iterations, to let
+// the garbage collector and the other goroutines run.
+func (t *typedTape[T]) runJIT(vm *BFVM) error {
+	program, ok := vm.compiled.(*jitProgram[T])
+	if !ok {
+		var err error
+		if program, err = compileJIT[T](vm); err != nil {
+			return err
+		}
+
+		vm.compiled = program
+	}
+
+	s := &closureState[T]{vm: vm, tape: t, cells: t.cells, pos: vm.position}
+	code := uintptr(unsafe.Pointer(&program.mem[0]))
+	state := &amd64.State{}
+
+	for ip := 0; ip < len(program.fallback); {
+		state.Tape = uintptr(unsafe.Pointer(&s.cells[0]))
+		state.Size = len(s.cells)
+		state.Pos = s.pos
+		state.Steps = s.steps
+		state.Resume = code + uintptr(program.entries[ip])
+
+		program.entry(state)
+		runtime.KeepAlive(s.cells)
+
+		s.pos, s.steps = state.Pos, state.Steps
+		ip = state.IP
+
+		// jumps only return to yield, and resume from the jump itself
+		if ip < len(program.fallback) && program.fallback[ip] == nil {
+			runtime.Gosched()
+			continue
+		}
+
+		if ip < len(program.fallback) {
+			if err := program.fallback[ip](s); err != nil {
+				return err
+			}
+
+			ip++
+		}
+	}
+
+	vm.position = s.pos
+	return nil
+}
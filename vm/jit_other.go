@@ -0,0 +1,10 @@
+//go:build !linux || !amd64
+
+package vm
+
+// jitSupported is true when BackendJIT can run on this platform
+const jitSupported = false
+
+func (t *typedTape[T]) runJIT(vm *BFVM) error {
+	return UnsupportedBackendError(BackendJIT)
+}
@@ -0,0 +1,250 @@
+package vm
+
+// EOFMode defines what the input command does when there is no more input
+type EOFMode int
+
+// List of the supported EOF modes
+const (
+	// EOFError stops the program with a RuntimeError wrapping io.EOF
+	EOFError EOFMode = iota
+
+	// EOFUnchanged leaves the current cell unchanged
+	EOFUnchanged
+
+	// EOFZero sets the current cell to zero
+	EOFZero
+
+	// EOFMinusOne sets the current cell to -1 (all bits set)
+	EOFMinusOne
+)
+
+var eofModeNames = []string{"error", "unchanged", "zero", "minus-one"}
+
+func (m EOFMode) String() string {
+	if m >= 0 && int(m) < len(eofModeNames) {
+		return eofModeNames[m]
+	}
+
+	return "unknown"
+}
+
+// ParseEOFMode returns the EOF mode with the specified name, as
+// returned by EOFMode.String
+func ParseEOFMode(name string) (EOFMode, error) {
+	for i, s := range eofModeNames {
+		if s == name {
+			return EOFMode(i), nil
+		}
+	}
+
+	return EOFError, InvalidModeError{"EOF", name}
+}
+
+// OutputMode defines how the output command encodes the current cell
+type OutputMode int
+
+// List of the supported output modes
+const (
+	// OutputUTF8 writes the cell value as an UTF-8 encoded code point
+	OutputUTF8 OutputMode = iota
+
+	// OutputByte writes the low 8 bits of the cell as a single raw byte
+	OutputByte
+
+	// OutputUTF16LE writes the cell value as UTF-16 code units, in little-endian
+	// order. Values up to 0xFFFF are written as a single code unit, as is.
+	OutputUTF16LE
+
+	// OutputDecimal writes the cell value as a decimal number, followed by
+	// the output delimiter
+	OutputDecimal
+)
+
+var outputModeNames = []string{"utf8", "byte", "utf16le", "decimal"}
+
+func (m OutputMode) String() string {
+	if m >= 0 && int(m) < len(outputModeNames) {
+		return outputModeNames[m]
+	}
+
+	return "unknown"
+}
+
+// ParseOutputMode returns the output mode with the specified name, as
+// returned by OutputMode.String
+func ParseOutputMode(name string) (OutputMode, error) {
+	for i, s := range outputModeNames {
+		if s == name {
+			return OutputMode(i), nil
+		}
+	}
+
+	return OutputUTF8, InvalidModeError{"output", name}
+}
+
+// InputMode defines how the input command decodes the values read
+type InputMode int
+
+// List of the supported input modes
+const (
+	// InputByte reads a single byte
+	InputByte InputMode = iota
+
+	// InputUTF8 reads an UTF-8 encoded code point. Invalid sequences are
+	// read as the unicode replacement character.
+	InputUTF8
+
+	// InputDecimal reads a whitespace-separated decimal integer. Negative
+	// numbers are stored as their two's complement.
+	InputDecimal
+)
+
+var inputModeNames = []string{"byte", "utf8", "decimal"}
+
+func (m InputMode) String() string {
+	if m >= 0 && int(m) < len(inputModeNames) {
+		return inputModeNames[m]
+	}
+
+	return "unknown"
+}
+
+// ParseInputMode returns the input mode with the specified name, as
+// returned by InputMode.String
+func ParseInputMode(name string) (InputMode, error) {
+	for i, s := range inputModeNames {
+		if s == name {
+			return InputMode(i), nil
+		}
+	}
+
+	return InputByte, InvalidModeError{"input", name}
+}
+
+// TapeMode defines what happens when the pointer moves past the ends of the tape
+type TapeMode int
+
+// List of the supported tape modes
+const (
+	// TapeWrap moves the pointer to the other end of the tape
+	TapeWrap TapeMode = iota
+
+	// TapeError stops the program with a RuntimeError wrapping an
+	// OutOfBoundsError. The optimizer passes that fold pointer moves are
+	// disabled, so every move past the ends fails.
+	TapeError
+
+	// TapeGrow extends the tape on demand to the right. Moving past the
+	// left end fails, as in TapeError.
+	TapeGrow
+
+	// TapeInfinite extends the tape on demand in both directions
+	TapeInfinite
+)
+
+var tapeModeNames = []string{"wrap", "error", "grow", "infinite"}
+
+func (m TapeMode) String() string {
+	if m >= 0 && int(m) < len(tapeModeNames) {
+		return tapeModeNames[m]
+	}
+
+	return "unknown"
+}
+
+// ParseTapeMode returns the tape mode with the specified name, as
+// returned by TapeMode.String
+func ParseTapeMode(name string) (TapeMode, error) {
+	for i, s := range tapeModeNames {
+		if s == name {
+			return TapeMode(i), nil
+		}
+	}
+
+	return TapeWrap, InvalidModeError{"tape", name}
+}
+
+// OverflowMode defines what happens when the arithmetic commands move a cell
+// value past its minimum or maximum value
+type OverflowMode int
+
+// List of the supported overflow modes
+const (
+	// OverflowWrap wraps the value around, to the other end of the range
+	OverflowWrap OverflowMode = iota
+
+	// OverflowSaturate keeps the value at the minimum or maximum
+	OverflowSaturate
+
+	// OverflowError stops the program with a RuntimeError wrapping a
+	// CellOverflowError
+	OverflowError
+)
+
+var overflowModeNames = []string{"wrap", "saturate", "error"}
+
+func (m OverflowMode) String() string {
+	if m >= 0 && int(m) < len(overflowModeNames) {
+		return overflowModeNames[m]
+	}
+
+	return "unknown"
+}
+
+// ParseOverflowMode returns the overflow mode with the specified name, as
+// returned by OverflowMode.String
+func ParseOverflowMode(name string) (OverflowMode, error) {
+	for i, s := range overflowModeNames {
+		if s == name {
+			return OverflowMode(i), nil
+		}
+	}
+
+	return OverflowWrap, InvalidModeError{"overflow", name}
+}
+
+// Backend defines how the virtual machine executes the loaded code
+type Backend int
+
+// List of the supported backends
+const (
+	// BackendBytecode interprets the instructions one by one
+	BackendBytecode Backend = iota
+
+	// BackendClosure compiles the instructions into a tree of Go closures,
+	// with their operands already bound, before running them
+	BackendClosure
+
+	// BackendJIT compiles the instructions into machine code before running
+	// them. It is only available on linux/amd64; see JITSupported.
+	//
+	// The machine code can not be preempted by the Go runtime, so it returns
+	// to Go at a loop every few thousand iterations, to let the garbage
+	// collector and the other goroutines run.
+	BackendJIT
+)
+
+// JITSupported is true when BackendJIT is available on this platform
+const JITSupported = jitSupported
+
+var backendNames = []string{"bytecode", "closure", "jit"}
+
+func (b Backend) String() string {
+	if b >= 0 && int(b) < len(backendNames) {
+		return backendNames[b]
+	}
+
+	return "unknown"
+}
+
+// ParseBackend returns the backend with the specified name, as
+// returned by Backend.String
+func ParseBackend(name string) (Backend, error) {
+	for i, s := range backendNames {
+		if s == name {
+			return Backend(i), nil
+		}
+	}
+
+	return BackendBytecode, InvalidModeError{"backend", name}
+}
@@ -0,0 +1,254 @@
+package vm_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/vm"
+)
+
+func TestParseEOFMode(t *testing.T) {
+	for _, mode := range []vm.EOFMode{vm.EOFError, vm.EOFUnchanged, vm.EOFZero, vm.EOFMinusOne} {
+		parsed, err := vm.ParseEOFMode(mode.String())
+
+		if err != nil || parsed != mode {
+			t.Errorf("Mode \"%v\", received \"%v\", \"%v\"", mode, parsed, err)
+		}
+	}
+
+	if _, err := vm.ParseEOFMode("nope"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	} else if !strings.Contains(err.Error(), "nope") {
+		t.Errorf("Wrong error message. Received \"%v\"", err.Error())
+	}
+}
+
+func TestEOFMode(t *testing.T) {
+	testCases := []struct {
+		mode     vm.EOFMode
+		size     int
+		expected uint64
+	}{
+		{mode: vm.EOFUnchanged, size: 8, expected: 5},
+		{mode: vm.EOFZero, size: 8, expected: 0},
+		{mode: vm.EOFMinusOne, size: 8, expected: 255},
+		{mode: vm.EOFMinusOne, size: 16, expected: 65535},
+		{mode: vm.EOFMinusOne, size: 32, expected: 4294967295},
+		{mode: vm.EOFMinusOne, size: 64, expected: 18446744073709551615},
+	}
+
+	for i, test := range testCases {
+		machine, err := vm.WithCellSize(test.size)
+
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		machine.SetEOFMode(test.mode)
+		machine.SetIO(strings.NewReader("A"), &strings.Builder{})
+
+		if err = machine.LoadFromString(",>+++++,"); err != nil {
+			t.Fatalf("Case %v, unexpected error: %v", i, err)
+		}
+
+		if err = machine.Run(); err != nil {
+			t.Errorf("Case %v, unexpected error: %v", i, err)
+		}
+
+		tape := machine.GetTapeState()
+
+		if tape[0].ToUint64() != 65 {
+			t.Errorf("Case %v, expected the first input to be read, received \"%v\"", i, tape[0])
+		}
+
+		if tape[1].ToUint64() != test.expected {
+			t.Errorf("Case %v, value mismatch. Expected \"%v\", received \"%v\"", i, test.expected, tape[1])
+		}
+	}
+}
+
+func TestEOFModeError(t *testing.T) {
+	machine, _ := vm.LoadFromString(",")
+	machine.SetIO(strings.NewReader(""), &strings.Builder{})
+
+	if err := machine.Run(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF by default, received \"%v\"", err)
+	}
+}
+
+func TestParseOutputMode(t *testing.T) {
+	for _, mode := range []vm.OutputMode{vm.OutputUTF8, vm.OutputByte, vm.OutputUTF16LE, vm.OutputDecimal} {
+		parsed, err := vm.ParseOutputMode(mode.String())
+
+		if err != nil || parsed != mode {
+			t.Errorf("Mode \"%v\", received \"%v\", \"%v\"", mode, parsed, err)
+		}
+	}
+
+	if _, err := vm.ParseOutputMode("nope"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+}
+
+func TestOutputMode(t *testing.T) {
+	// 200, 65 and 0x1F600 (an emoji) in the first three cells
+	source := strings.Repeat("+", 200) + ">" + strings.Repeat("+", 65) + ">" + strings.Repeat("+", 251) +
+		"[->++++++++<]>[-<++++++++>]<[->++++++++<]>[-<+>]<<<.>.>."
+
+	testCases := []struct {
+		mode      vm.OutputMode
+		size      int
+		delimiter string
+		expected  string
+	}{
+		{mode: vm.OutputUTF8, size: 8, expected: "ÈA\x00"},
+		{mode: vm.OutputByte, size: 8, expected: "\xc8A\x00"},
+		{mode: vm.OutputByte, size: 32, expected: "\xc8A\x00"},
+		{mode: vm.OutputUTF8, size: 32, expected: "ÈA\U0001F600"},
+		{mode: vm.OutputUTF16LE, size: 16, expected: "\xc8\x00A\x00\x00\xf6"},
+		{mode: vm.OutputUTF16LE, size: 32, expected: "\xc8\x00A\x00\x3d\xd8\x00\xde"},
+		{mode: vm.OutputDecimal, size: 32, expected: "200 65 128512 "},
+		{mode: vm.OutputDecimal, size: 8, delimiter: ",", expected: "200,65,0,"},
+	}
+
+	for i, test := range testCases {
+		machine, err := vm.WithCellSize(test.size)
+
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if err = machine.LoadFromString(source); err != nil {
+			t.Fatalf("Case %v, unexpected error: %v", i, err)
+		}
+
+		writer := strings.Builder{}
+		machine.SetIO(strings.NewReader(""), &writer)
+		machine.SetOutputMode(test.mode)
+
+		if test.delimiter != "" {
+			machine.SetOutputDelimiter(test.delimiter)
+		}
+
+		if err = machine.Run(); err != nil {
+			t.Errorf("Case %v, unexpected error: %v", i, err)
+		}
+
+		if writer.String() != test.expected {
+			t.Errorf("Case %v, expected output to be \"%q\", but it was \"%q\".", i, test.expected, writer.String())
+		}
+	}
+}
+
+func TestOutputModeOutOfRange(t *testing.T) {
+	testCases := []struct {
+		mode     vm.OutputMode
+		expected string
+	}{
+		{mode: vm.OutputUTF8, expected: "\xef\xbf\xbd"},
+		{mode: vm.OutputUTF16LE, expected: "\xfd\xff"},
+	}
+
+	for i, test := range testCases {
+		machine, _ := vm.WithCellSize(32)
+
+		if err := machine.LoadFromString("-."); err != nil {
+			t.Fatalf("Case %v, unexpected error: %v", i, err)
+		}
+
+		writer := strings.Builder{}
+		machine.SetIO(strings.NewReader(""), &writer)
+		machine.SetOutputMode(test.mode)
+
+		if err := machine.Run(); err != nil {
+			t.Errorf("Case %v, unexpected error: %v", i, err)
+		}
+
+		if writer.String() != test.expected {
+			t.Errorf("Case %v, expected output to be \"%q\", but it was \"%q\".", i, test.expected, writer.String())
+		}
+	}
+}
+
+func TestParseInputMode(t *testing.T) {
+	for _, mode := range []vm.InputMode{vm.InputByte, vm.InputUTF8, vm.InputDecimal} {
+		parsed, err := vm.ParseInputMode(mode.String())
+
+		if err != nil || parsed != mode {
+			t.Errorf("Mode \"%v\", received \"%v\", \"%v\"", mode, parsed, err)
+		}
+	}
+
+	if _, err := vm.ParseInputMode("nope"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+}
+
+func TestInputMode(t *testing.T) {
+	testCases := []struct {
+		mode     vm.InputMode
+		size     int
+		inputs   string
+		expected []uint64
+	}{
+		{mode: vm.InputByte, size: 16, inputs: "Aç", expected: []uint64{65, 0xC3, 0xA7}},
+		{mode: vm.InputUTF8, size: 8, inputs: "Aç\u0141", expected: []uint64{65, 0xE7, 0x41}},
+		{mode: vm.InputUTF8, size: 32, inputs: "ç\U0001F600\xff", expected: []uint64{0xE7, 0x1F600, 0xFFFD}},
+		{mode: vm.InputDecimal, size: 16, inputs: "  300\n65535 7", expected: []uint64{300, 65535, 7}},
+		{mode: vm.InputDecimal, size: 8, inputs: "300 -1 0", expected: []uint64{44, 255, 0}},
+		{mode: vm.InputDecimal, size: 64, inputs: "18446744073709551615\t-2 1", expected: []uint64{18446744073709551615, 18446744073709551614, 1}},
+	}
+
+	for i, test := range testCases {
+		machine, err := vm.WithCellSize(test.size)
+
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if err = machine.LoadFromString(",>,>,"); err != nil {
+			t.Fatalf("Case %v, unexpected error: %v", i, err)
+		}
+
+		machine.SetIO(strings.NewReader(test.inputs), &strings.Builder{})
+		machine.SetInputMode(test.mode)
+
+		if err = machine.Run(); err != nil {
+			t.Errorf("Case %v, unexpected error: %v", i, err)
+		}
+
+		tape := machine.GetTapeState()
+		for j, expected := range test.expected {
+			if tape[j].ToUint64() != expected {
+				t.Errorf("Case %v, cell %v, value mismatch. Expected \"%v\", received \"%v\"", i, j, expected, tape[j])
+			}
+		}
+	}
+}
+
+func TestInputDecimalErrors(t *testing.T) {
+	testCases := []struct {
+		inputs string
+		eof    bool
+	}{
+		{inputs: "12 abc"},
+		{inputs: "12 99999999999999999999"},
+		{inputs: "12   ", eof: true},
+	}
+
+	for i, test := range testCases {
+		machine, _ := vm.LoadFromString(",>,")
+		machine.SetIO(strings.NewReader(test.inputs), &strings.Builder{})
+		machine.SetInputMode(vm.InputDecimal)
+		err := machine.Run()
+
+		var numErr vm.InvalidNumberError
+		if test.eof && !errors.Is(err, io.EOF) {
+			t.Errorf("Case %v, expected io.EOF, received \"%v\"", i, err)
+		} else if !test.eof && !errors.As(err, &numErr) {
+			t.Errorf("Case %v, expected InvalidNumberError, received \"%v\"", i, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package vm
+
+import (
+	"github.com/ibraimgm/bfi/interpreter/parser"
+)
+
+// op is the compact form of an instruction, used by the execution loop.
+// The spans are only needed when reporting errors, so they are looked up
+// in vm.commands instead.
+type op struct {
+	cmd    parser.Command
+	arg    int
+	offset int
+}
+
+func compile(commands []parser.Instruction) []op {
+	ops := make([]op, len(commands))
+
+	for i, ins := range commands {
+		ops[i] = op{cmd: ins.Cmd, arg: ins.Arg, offset: ins.Offset}
+	}
+
+	return ops
+}
+
+// run executes the loaded code on a tape of cells of type T. The pointer is
+// kept in a local variable, and only written back to vm.position when
+// something else needs it: moving past the ends of the tape, reporting an
+// error or finishing the execution.
+func (t *typedTape[T]) run(vm *BFVM) error {
+	ops := vm.ops
+	cells := t.cells
+	pos := vm.position
+	wrap := vm.overflow == OverflowWrap
+	var steps uint64
+
+	// relocate applies the tape mode to a position outside of the tape
+	relocate := func(position int) (int, error) {
+		vm.position = pos
+		index, err := t.locate(vm, position)
+		cells = t.cells
+		pos = vm.position
+		return index, err
+	}
+
+	fail := func(ip int, err error) error {
+		vm.position = pos
+		return vm.runtimeError(ip, steps, err)
+	}
+
+	for i := 0; i < len(ops); i++ {
+		steps++
+		ins := &ops[i]
+		target := pos
+
+		if ins.offset != 0 {
+			target = pos + ins.offset
+
+			if uint(target) >= uint(len(cells)) {
+				var err error
+				if target, err = relocate(target); err != nil {
+					return fail(i, err)
+				}
+			}
+		}
+
+		switch ins.cmd {
+		case parser.CmdMove:
+			next := pos + ins.arg
+
+			if uint(next) >= uint(len(cells)) {
+				var err error
+				if next, err = relocate(next); err != nil {
+					return fail(i, err)
+				}
+			}
+
+			pos = next
+
+		case parser.CmdAdd:
+			if wrap {
+				cells[target] += T(ins.arg)
+				continue
+			}
+
+			value, err := vm.add(uint64(cells[target]), ins.arg)
+			if err != nil {
+				return fail(i, err)
+			}
+
+			cells[target] = T(value)
+
+		case parser.CmdClear:
+			cells[target] = 0
+
+		case parser.CmdMulAdd:
+			// the product wraps around just like repeated additions would.
+			// This is never generated unless the overflow mode is OverflowWrap.
+			cells[target] += T(ins.arg) * cells[pos]
+
+		case parser.CmdScan:
+			if cells[pos] != 0 {
+				pos, _ = scanBytes(cells, pos, ins.arg)
+			}
+
+			for cells[pos] != 0 {
+				next := pos + ins.arg
+
+				if uint(next) >= uint(len(cells)) {
+					var err error
+					if next, err = relocate(next); err != nil {
+						return fail(i, err)
+					}
+				}
+
+				pos = next
+			}
+
+		case parser.CmdJump:
+			if cells[pos] == 0 {
+				i = ins.arg
+			}
+
+		case parser.CmdReturn:
+			if cells[pos] != 0 {
+				i = ins.arg - 1
+			}
+
+		case parser.CmdInput:
+			value, err := vm.input(uint64(cells[pos]))
+			if err != nil {
+				return fail(i, err)
+			}
+
+			cells[pos] = T(value)
+
+		case parser.CmdOutput:
+			if err := vm.output(uint64(cells[pos])); err != nil {
+				return fail(i, err)
+			}
+		}
+	}
+
+	vm.position = pos
+	return nil
+}
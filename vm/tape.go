@@ -0,0 +1,140 @@
+package vm
+
+import (
+	"bytes"
+)
+
+// cellType lists the integer types used to store the cells of each width
+type cellType interface {
+	~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// tape stores the cells of the virtual machine. Each cell width has its own
+// implementation, so the execution loop works directly on a typed slice
+// instead of boxing every cell.
+type tape interface {
+	size() int
+	value(index int) uint64
+	zero()
+	run(vm *BFVM) error
+	runClosures(vm *BFVM) error
+	runJIT(vm *BFVM) error
+}
+
+type typedTape[T cellType] struct {
+	cells []T
+}
+
+func newTape(cellSize int, tapeSize int) (tape, error) {
+	switch cellSize {
+	case 8:
+		return &typedTape[uint8]{make([]uint8, tapeSize)}, nil
+	case 16:
+		return &typedTape[uint16]{make([]uint16, tapeSize)}, nil
+	case 32:
+		return &typedTape[uint32]{make([]uint32, tapeSize)}, nil
+	case 64:
+		return &typedTape[uint64]{make([]uint64, tapeSize)}, nil
+	default:
+		return nil, InvalidCellSizeError(cellSize)
+	}
+}
+
+func (t *typedTape[T]) size() int {
+	return len(t.cells)
+}
+
+func (t *typedTape[T]) value(index int) uint64 {
+	return uint64(t.cells[index])
+}
+
+func (t *typedTape[T]) zero() {
+	for i := range t.cells {
+		t.cells[i] = 0
+	}
+}
+
+// locate returns the tape index of the specified position, applying the tape
+// mode when it falls outside of the tape. Folded moves and offsets might be
+// larger than the tape itself.
+//
+// Growing the tape to the left shifts every cell, so vm.position and vm.origin
+// are updated to keep pointing to the same cells.
+func (t *typedTape[T]) locate(vm *BFVM, position int) (int, error) {
+	size := len(t.cells)
+
+	if position >= 0 && position < size {
+		return position, nil
+	}
+
+	switch vm.tapeMode {
+	case TapeWrap:
+		return (position%size + size) % size, nil
+
+	case TapeGrow, TapeInfinite:
+		if position >= size {
+			t.growRight(position - size + 1)
+			return position, nil
+		}
+
+		if vm.tapeMode == TapeInfinite {
+			shift := t.growLeft(-position)
+			vm.position += shift
+			vm.origin += shift
+			return position + shift, nil
+		}
+	}
+
+	return 0, OutOfBoundsError(position - vm.origin)
+}
+
+// growRight appends at least n cells to the end of the tape
+func (t *typedTape[T]) growRight(n int) {
+	if n < len(t.cells) {
+		n = len(t.cells)
+	}
+
+	t.cells = append(t.cells, make([]T, n)...)
+}
+
+// growLeft inserts at least n cells at the start of the tape, returning the
+// number of cells inserted
+func (t *typedTape[T]) growLeft(n int) int {
+	if n < len(t.cells) {
+		n = len(t.cells)
+	}
+
+	cells := make([]T, n, n+len(t.cells))
+	t.cells = append(cells, t.cells...)
+	return n
+}
+
+// scanBytes moves from pos to the nearest zero cell, in steps of stride.
+// When the stride is 1 or -1 and the tape is made of bytes, the search is done
+// by the bytes package. If there is no zero cell up to the end of the tape,
+// it returns false along with the last cell checked, and the search must
+// continue past the end of the tape.
+func scanBytes[T cellType](cells []T, pos int, stride int) (int, bool) {
+	b, ok := any(cells).([]uint8)
+	if !ok {
+		return pos, false
+	}
+
+	switch stride {
+	case 1:
+		if i := bytes.IndexByte(b[pos:], 0); i >= 0 {
+			return pos + i, true
+		}
+
+		return len(b) - 1, false
+
+	case -1:
+		if i := bytes.LastIndexByte(b[:pos+1], 0); i >= 0 {
+			return i, true
+		}
+
+		return 0, false
+	}
+
+	return pos, false
+}
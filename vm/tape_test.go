@@ -0,0 +1,158 @@
+package vm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+	"github.com/ibraimgm/bfi/interpreter/parser"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+func TestParseTapeMode(t *testing.T) {
+	for _, mode := range []vm.TapeMode{vm.TapeWrap, vm.TapeError, vm.TapeGrow, vm.TapeInfinite} {
+		parsed, err := vm.ParseTapeMode(mode.String())
+
+		if err != nil || parsed != mode {
+			t.Errorf("Mode \"%v\", received \"%v\", \"%v\"", mode, parsed, err)
+		}
+	}
+
+	if _, err := vm.ParseTapeMode("nope"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+}
+
+func TestTapeMode(t *testing.T) {
+	testCases := []struct {
+		mode   vm.TapeMode
+		source string
+		size   int
+		origin int
+		cells  []int
+	}{
+		{mode: vm.TapeWrap, source: `<+`, size: 3, cells: []int{2}},
+		{mode: vm.TapeWrap, source: `>>>>+<<<<<<<+`, size: 3, cells: []int{1, 0}},
+		{mode: vm.TapeGrow, source: `>>>>>+`, size: 6, cells: []int{5}},
+		{mode: vm.TapeGrow, source: `+>+>+[>]+`, size: 6, cells: []int{0, 1, 2, 3}},
+		{mode: vm.TapeGrow, source: `>>>>>>>>>>>>>>>>+<<<<<<<<<<<<<<<<+`, size: 17, cells: []int{0, 16}},
+		{mode: vm.TapeInfinite, source: `<<+>>>>>>+`, size: 9, origin: 3, cells: []int{1, 7}},
+		{mode: vm.TapeInfinite, source: `+<+<+[<]+`, size: 6, origin: 3, cells: []int{0, 1, 2, 3}},
+		{mode: vm.TapeInfinite, source: `+[>+<-]<<<<<<<<+`, size: 11, origin: 8, cells: []int{0, 9}},
+	}
+
+	for i, test := range testCases {
+		for level := 0; level <= optimizer.MaxLevel; level++ {
+			machine, err := vm.WithSize(3)
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			pipeline, _ := optimizer.New(level)
+			machine.SetPipeline(pipeline)
+			machine.SetTapeMode(test.mode)
+
+			if err = machine.LoadFromString(test.source); err != nil {
+				t.Fatalf("Case %v, unexpected error: %v", i, err)
+			}
+
+			if err = machine.Run(); err != nil {
+				t.Errorf("Case %v, level %v, unexpected error: %v", i, level, err)
+				continue
+			}
+
+			tape := machine.GetTapeState()
+			if len(tape) < test.size {
+				t.Errorf("Case %v, level %v, expected at least %v cells, received %v", i, level, test.size, len(tape))
+				continue
+			}
+
+			if machine.Origin() != test.origin {
+				t.Errorf("Case %v, level %v, expected origin %v, received %v", i, level, test.origin, machine.Origin())
+			}
+
+			for _, c := range test.cells {
+				if tape[c].ToUint8() != 1 {
+					t.Errorf("Case %v, level %v, cell %v, expected \"1\", received \"%v\"", i, level, c, tape[c])
+				}
+			}
+		}
+	}
+}
+
+func TestTapeModeErrors(t *testing.T) {
+	testCases := []struct {
+		mode     vm.TapeMode
+		source   string
+		position int
+	}{
+		{mode: vm.TapeError, source: `<+`, position: -1},
+		{mode: vm.TapeError, source: `>>>+`, position: 3},
+		{mode: vm.TapeError, source: `+>+>+[>]`, position: 3},
+		{mode: vm.TapeGrow, source: `>><<<+`, position: -1},
+		{mode: vm.TapeGrow, source: `+[<]`, position: -1},
+		{mode: vm.TapeError, source: `-<<>>>-`, position: -2},
+		{mode: vm.TapeError, source: `>>+[->+<]`, position: 3},
+		{mode: vm.TapeGrow, source: `>+<<>>[-<<+>>]`, position: -1},
+	}
+
+	for i, test := range testCases {
+		var span parser.Span
+
+		for level := 0; level <= optimizer.MaxLevel; level++ {
+			machine, _ := vm.WithSize(3)
+			pipeline, _ := optimizer.New(level)
+			machine.SetPipeline(pipeline)
+			machine.SetTapeMode(test.mode)
+
+			if err := machine.LoadFromString(test.source); err != nil {
+				t.Fatalf("Case %v, unexpected error: %v", i, err)
+			}
+
+			err := machine.Run()
+
+			var runtimeErr *vm.RuntimeError
+			var boundsErr vm.OutOfBoundsError
+
+			if !errors.As(err, &runtimeErr) || !errors.As(err, &boundsErr) {
+				t.Errorf("Case %v, level %v, expected an OutOfBoundsError, received \"%v\"", i, level, err)
+			} else if int(boundsErr) != test.position {
+				t.Errorf("Case %v, level %v, expected position %v, received %v", i, level, test.position, int(boundsErr))
+			} else if level == 0 {
+				span = runtimeErr.Span
+			} else if runtimeErr.Span.Start.Offset > span.Start.Offset || runtimeErr.Span.End.Offset < span.End.Offset {
+				// optimized instructions report the span of every command they replace
+				t.Errorf("Case %v, level %v, expected the error around %v, received %v", i, level, span, runtimeErr.Span)
+			}
+		}
+	}
+}
+
+func TestTapeModeNoErrors(t *testing.T) {
+	testCases := []struct {
+		mode   vm.TapeMode
+		source string
+	}{
+		{mode: vm.TapeError, source: `>>[->+<]`},
+		{mode: vm.TapeError, source: `+>+<[>]`},
+		{mode: vm.TapeGrow, source: `[-<+>]>>>>+`},
+	}
+
+	for i, test := range testCases {
+		for level := 0; level <= optimizer.MaxLevel; level++ {
+			machine, _ := vm.WithSize(3)
+			pipeline, _ := optimizer.New(level)
+			machine.SetPipeline(pipeline)
+			machine.SetTapeMode(test.mode)
+
+			if err := machine.LoadFromString(test.source); err != nil {
+				t.Fatalf("Case %v, unexpected error: %v", i, err)
+			}
+
+			if err := machine.Run(); err != nil {
+				t.Errorf("Case %v, level %v, unexpected error: %v", i, level, err)
+			}
+		}
+	}
+}
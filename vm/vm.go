@@ -1,46 +1,72 @@
 package vm
 
 import (
-	"bytes"
-	"fmt"
+	"bufio"
 	"io"
 	"os"
 	"strings"
 
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
 	"github.com/ibraimgm/bfi/interpreter/parser"
 )
 
+// defaultBackend is the backend of new machines. It is only changed by
+// the tests, to run them against every backend.
+var defaultBackend = BackendBytecode
+
 // BFVM is a virtual machine capable of loading and running brainf*ck code
 type BFVM struct {
-	commands []byte
-	tape     []Cell
-	jumps    map[int]int
-	stdin    io.Reader
+	commands []parser.Instruction
+	ops      []op
+	compiled interface{}
+	backend  Backend
+	tape     tape
+	stdin    *bufio.Reader
 	stdout   io.Writer
 	position int
+	origin   int
+	cellSize int
+	tapeMode TapeMode
+	overflow OverflowMode
+	signed   bool
+	pipeline *optimizer.Pipeline
+	eofMode  EOFMode
+	inMode   InputMode
+	outMode  OutputMode
+	outDelim string
+	outBuf   []byte
 }
 
 // LoadFromStream loads the brainf*ck source from the specified reader
 // into the virtual machine instance
 func (vm *BFVM) LoadFromStream(reader io.Reader) error {
-	buf := new(bytes.Buffer)
-	if _, err := buf.ReadFrom(parser.Parse(reader)); err != nil {
+	commands, err := parser.Parse(reader)
+	if err != nil {
 		return err
 	}
 
-	vm.commands = buf.Bytes()
-	vm.position = 0
-
-	s := newStack()
+	if vm.pipeline != nil {
+		pipeline := vm.pipeline
 
-	for i, cmd := range vm.commands {
-		cmd, qty := parser.ExtractCommand(cmd)
+		if vm.overflow != OverflowWrap {
+			pipeline = pipeline.WithoutWrapping()
+		}
 
-		if qty > 0 {
-			continue
+		if vm.tapeMode == TapeError || vm.tapeMode == TapeGrow {
+			pipeline = pipeline.WithoutFoldedMoves()
 		}
 
-		switch cmd {
+		commands = pipeline.Run(commands)
+	}
+
+	vm.commands = commands
+	vm.ops = nil
+	vm.position = 0
+
+	s := newStack()
+
+	for i, ins := range vm.commands {
+		switch ins.Cmd {
 		case parser.CmdJump:
 			s.push(i)
 		case parser.CmdReturn:
@@ -50,11 +76,13 @@ func (vm *BFVM) LoadFromStream(reader io.Reader) error {
 				return err
 			}
 
-			vm.jumps[addr] = i
-			vm.jumps[i] = addr
+			vm.commands[addr].Arg = i
+			vm.commands[i].Arg = addr
 		}
 	}
 
+	vm.ops = compile(vm.commands)
+	vm.compiled = nil
 	return nil
 }
 
@@ -65,103 +93,163 @@ func (vm *BFVM) LoadFromString(source string) error {
 
 // SetIO sets the input/output stream used by the virtual machine
 func (vm *BFVM) SetIO(in io.Reader, out io.Writer) {
-	vm.stdin = in
+	vm.stdin = bufio.NewReader(in)
 	vm.stdout = out
 }
 
-// GetTapeState returns a copy of the current tape contents
-func (vm *BFVM) GetTapeState() []Cell {
-	tmp := make([]Cell, len(vm.tape))
+// SetTapeMode sets what happens when the pointer moves past the ends of the
+// tape. The default is TapeWrap.
+//
+// Some optimizations are only correct when moving the pointer never fails, so
+// the mode must be set before loading the code.
+func (vm *BFVM) SetTapeMode(mode TapeMode) {
+	vm.tapeMode = mode
+}
 
-	for i, c := range vm.tape {
-		tmp[i] = c.Clone()
-	}
+// SetOverflowMode sets what happens when an arithmetic command moves a cell
+// past its minimum or maximum value. The default is OverflowWrap.
+//
+// Some optimizations are only correct when the arithmetic wraps around, so
+// the mode must be set before loading the code.
+func (vm *BFVM) SetOverflowMode(mode OverflowMode) {
+	vm.overflow = mode
+	vm.compiled = nil
+}
 
-	return tmp
+// SetBackend sets how the loaded code is executed. The default is
+// BackendBytecode.
+func (vm *BFVM) SetBackend(backend Backend) {
+	vm.backend = backend
 }
 
-// Run executes the currently loaded brainf*ck code.
-// The current position or the values of the cells are not initialized; for that, use Reset().
-func (vm *BFVM) Run() error {
-	maxCells := len(vm.tape)
-	maxCmds := len(vm.commands)
-	buffer := make([]byte, 1)
+// SetSigned sets whether the cells hold signed (two's complement) values.
+// This changes the range checked by the overflow mode and how values are
+// written by OutputDecimal. The default is unsigned.
+func (vm *BFVM) SetSigned(signed bool) {
+	vm.signed = signed
+}
 
-	for i := 0; i < maxCmds; i++ {
-		b := vm.commands[i]
-		cmd, qty := parser.ExtractCommand(b)
+// Origin returns the index of the initial cell in the tape returned by
+// GetTapeState. It only changes when TapeInfinite grows the tape to the left.
+func (vm *BFVM) Origin() int {
+	return vm.origin
+}
 
-		cell := vm.tape[vm.position]
+// SetPipeline sets the optimizer pipeline used on the code loaded afterwards.
+// By default, every optimization is enabled; a nil pipeline disables them all.
+func (vm *BFVM) SetPipeline(pipeline *optimizer.Pipeline) {
+	vm.pipeline = pipeline
+}
 
-		switch {
-		case cmd == parser.CmdMoveRight && qty > 0:
-			vm.position += int(qty)
-			if vm.position >= maxCells {
-				vm.position -= maxCells
-			}
+// SetEOFMode sets what the input command does when there is no more input.
+// The default is EOFError.
+func (vm *BFVM) SetEOFMode(mode EOFMode) {
+	vm.eofMode = mode
+}
 
-		case cmd == parser.CmdMoveLeft && qty > 0:
-			vm.position -= int(qty)
-			if vm.position < 0 {
-				vm.position += maxCells
-			}
+// SetInputMode sets how the input command decodes the values read.
+// The default is InputByte.
+func (vm *BFVM) SetInputMode(mode InputMode) {
+	vm.inMode = mode
+}
 
-		case cmd == parser.CmdInc && qty > 0:
-			cell.Add(qty)
+// SetOutputMode sets how the output command encodes the cell values.
+// The default is OutputUTF8.
+func (vm *BFVM) SetOutputMode(mode OutputMode) {
+	vm.outMode = mode
+}
 
-		case cmd == parser.CmdDec && qty > 0:
-			cell.Subtract(qty)
+// SetOutputDelimiter sets the text written after each number, when using
+// OutputDecimal. The default is a single space.
+func (vm *BFVM) SetOutputDelimiter(delimiter string) {
+	vm.outDelim = delimiter
+}
 
-		case cmd == parser.CmdJump:
-			if cell.IsZero() {
-				i = vm.jumps[i]
-			}
+// SourceMap returns the span of source code that generated each of the loaded
+// instructions, indexed by instruction pointer
+func (vm *BFVM) SourceMap() []parser.Span {
+	spans := make([]parser.Span, len(vm.commands))
 
-		case cmd == parser.CmdReturn:
-			if !cell.IsZero() {
-				i = vm.jumps[i] - 1
-			}
+	for i, ins := range vm.commands {
+		spans[i] = ins.Span
+	}
 
-		case cmd == parser.CmdInput:
-			if _, err := vm.stdin.Read(buffer); err != nil {
-				return err
-			}
+	return spans
+}
 
-			cell.Zero()
-			cell.Add(buffer[0])
+// SourceSpan returns the span of source code that generated the instruction
+// at ip, or false if there is no such instruction
+func (vm *BFVM) SourceSpan(ip int) (parser.Span, bool) {
+	if ip < 0 || ip >= len(vm.commands) {
+		return parser.Span{}, false
+	}
 
-		case cmd == parser.CmdOutput:
-			runes := []rune{rune(cell.ToUint32())}
-			fmt.Fprintf(vm.stdout, "%v", string(runes))
-		}
+	return vm.commands[ip].Span, true
+}
+
+// GetTapeState returns a copy of the current tape contents
+func (vm *BFVM) GetTapeState() []Cell {
+	tmp := make([]Cell, vm.tape.size())
+
+	for i := range tmp {
+		tmp[i], _ = newCell(vm.cellSize)
+		tmp[i].Add(vm.tape.value(i))
 	}
 
-	return nil
+	return tmp
 }
 
-// Reset resets both the position of the tape and the cell values to 0.
-func (vm *BFVM) Reset() {
-	vm.position = 0
+// CellValue returns the value of the cell at the specified index of the tape,
+// without copying the whole tape. Indexes are the same used by GetTapeState.
+func (vm *BFVM) CellValue(index int) uint64 {
+	return vm.tape.value(index)
+}
 
-	for _, c := range vm.tape {
-		c.Zero()
+// Run executes the currently loaded brainf*ck code.
+// The current position or the values of the cells are not initialized; for that, use Reset().
+func (vm *BFVM) Run() error {
+	switch vm.backend {
+	case BackendClosure:
+		return vm.tape.runClosures(vm)
+	case BackendJIT:
+		return vm.tape.runJIT(vm)
+	default:
+		return vm.tape.run(vm)
+	}
+}
+
+// runtimeError wraps err with the current state of the virtual machine
+func (vm *BFVM) runtimeError(ip int, steps uint64, err error) *RuntimeError {
+	return &RuntimeError{
+		IP:      ip,
+		Span:    vm.commands[ip].Span,
+		Pointer: vm.position,
+		Value:   vm.tape.value(vm.position),
+		Steps:   steps,
+		Err:     err,
 	}
 }
 
+// Reset resets both the position of the tape and the cell values to 0.
+// Cells added by growing the tape are kept.
+func (vm *BFVM) Reset() {
+	vm.position = vm.origin
+	vm.tape.zero()
+}
+
 // WithSpecs returns a new VM instance, with the specified cell size and tape size
 func WithSpecs(cellSize int, tapeSize int) (*BFVM, error) {
-	tape := make([]Cell, tapeSize)
-	var err error
-
-	for i := 0; i < tapeSize; i++ {
-		tape[i], err = newCell(cellSize)
+	tape, err := newTape(cellSize, tapeSize)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	pipeline, err := optimizer.New(optimizer.MaxLevel)
+	if err != nil {
+		return nil, err
 	}
 
-	return &BFVM{tape: tape, jumps: make(map[int]int), stdin: os.Stdin, stdout: os.Stdout}, nil
+	return &BFVM{tape: tape, cellSize: cellSize, stdin: bufio.NewReader(os.Stdin), stdout: os.Stdout, pipeline: pipeline, outDelim: " ", backend: defaultBackend}, nil
 }
 
 // WithCellSize returns a new VM instance, with the specified cell size
@@ -0,0 +1,85 @@
+package vm_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+	"github.com/ibraimgm/bfi/vm"
+)
+
+// byteOnly marks programs that rely on 8-bit wraparound, and take too long on
+// wider cells
+var benchPrograms = []struct {
+	name     string
+	byteOnly bool
+	source   string
+}{
+	{name: "hello", source: "++++++++[>++++[>++>+++>+++>+<<<<-]>+>+>->>+[<]<-]>>.>---.+++++++..+++.>>.<-.<.+++.------.--------.>>+.>++."},
+	{name: "hello2", byteOnly: true, source: "+[-->-[>>+>-----<<]<--<---]>-.>>>+.>>..+++[.>]<<<<.+++.------.<<-.>>>>+."},
+	{name: "countdown", source: ">+++++++++>>>>>>>>>>++++++++[-<<<<<<<<<<<>[>]<-[->+>+<<]>>[-<<+>>]<<+[<]>>>>>>>>>>>]<<[<]>[[-<+>]>]"},
+	{name: "nested", source: "++++++++[>++++++++[>++++++++[>++++++++[>+>++<<-]<-]<-]<-]>>>>[<]"},
+}
+
+func benchmarkRun(b *testing.B, cellSize int, level int, backend vm.Backend) {
+	for _, program := range benchPrograms {
+		if program.byteOnly && cellSize != 8 {
+			continue
+		}
+
+		program := program
+		b.Run(program.name, func(b *testing.B) {
+			machine, err := vm.WithCellSize(cellSize)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			pipeline, _ := optimizer.New(level)
+			machine.SetPipeline(pipeline)
+			machine.SetBackend(backend)
+
+			if err := machine.LoadFromString(program.source); err != nil {
+				b.Fatal(err)
+			}
+
+			machine.SetIO(strings.NewReader(""), ioutil.Discard)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				machine.Reset()
+
+				if err := machine.Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRun8(b *testing.B)          { benchmarkRun(b, 8, 0, vm.BackendBytecode) }
+func BenchmarkRun16(b *testing.B)         { benchmarkRun(b, 16, 0, vm.BackendBytecode) }
+func BenchmarkRun32(b *testing.B)         { benchmarkRun(b, 32, 0, vm.BackendBytecode) }
+func BenchmarkRun64(b *testing.B)         { benchmarkRun(b, 64, 0, vm.BackendBytecode) }
+func BenchmarkRun8Optimized(b *testing.B) { benchmarkRun(b, 8, optimizer.MaxLevel, vm.BackendBytecode) }
+
+func BenchmarkRun8Closure(b *testing.B) { benchmarkRun(b, 8, 0, vm.BackendClosure) }
+func BenchmarkRun8ClosureOptimized(b *testing.B) {
+	benchmarkRun(b, 8, optimizer.MaxLevel, vm.BackendClosure)
+}
+
+func BenchmarkRun8JIT(b *testing.B) {
+	if !vm.JITSupported {
+		b.Skip("the JIT backend is not supported on this platform")
+	}
+
+	benchmarkRun(b, 8, optimizer.MaxLevel, vm.BackendJIT)
+}
+
+func BenchmarkGetTapeState(b *testing.B) {
+	machine, _ := vm.New()
+
+	for i := 0; i < b.N; i++ {
+		machine.GetTapeState()
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/ibraimgm/bfi/interpreter/optimizer"
+	"github.com/ibraimgm/bfi/interpreter/parser"
 	"github.com/ibraimgm/bfi/vm"
 )
 
@@ -116,6 +118,46 @@ func TestGetTapeStateReturnsACopy(t *testing.T) {
 	}
 }
 
+func TestCellValue(t *testing.T) {
+	testCases := []struct {
+		cellSize int
+		source   string
+		expected []uint64
+	}{
+		{cellSize: 8, source: `+>++>-`, expected: []uint64{1, 2, 0xFF}},
+		{cellSize: 16, source: `+>++>-`, expected: []uint64{1, 2, 0xFFFF}},
+		{cellSize: 32, source: `+>++>-`, expected: []uint64{1, 2, 0xFFFFFFFF}},
+		{cellSize: 64, source: `+>++>-`, expected: []uint64{1, 2, 0xFFFFFFFFFFFFFFFF}},
+	}
+
+	for i, test := range testCases {
+		machine, err := vm.WithSpecs(test.cellSize, 3)
+		if err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		if err := machine.LoadFromString(test.source); err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		if err := machine.Run(); err != nil {
+			t.Fatalf("Case %v, %v", i, err)
+		}
+
+		state := machine.GetTapeState()
+
+		for j, expected := range test.expected {
+			if value := machine.CellValue(j); value != expected {
+				t.Errorf("Case %v, cell %v should be %v, but was %v", i, j, expected, value)
+			}
+
+			if value := state[j].ToUint64(); value != expected {
+				t.Errorf("Case %v, tape state cell %v should be %v, but was %v", i, j, expected, value)
+			}
+		}
+	}
+}
+
 func TestIO(t *testing.T) {
 	testCases := []struct {
 		source  string
@@ -207,3 +249,197 @@ func TestIO(t *testing.T) {
 		}
 	}
 }
+
+func TestLongMoves(t *testing.T) {
+	testCases := []struct {
+		tapeSize int
+		source   string
+		cell     int
+	}{
+		{tapeSize: 10, source: strings.Repeat(">", 25) + "+", cell: 5},
+		{tapeSize: 10, source: strings.Repeat("<", 25) + "+", cell: 5},
+		{tapeSize: 10, source: strings.Repeat(">", 10) + "+", cell: 0},
+		{tapeSize: 3000, source: strings.Repeat(">", 3001) + "+", cell: 1},
+		{tapeSize: 3000, source: strings.Repeat("<", 6001) + "+", cell: 2999},
+	}
+
+	for i, test := range testCases {
+		machine, err := vm.WithSize(test.tapeSize)
+
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if err = machine.LoadFromString(test.source); err != nil {
+			t.Errorf("Case %v, unexpected error: %v", i, err)
+		}
+
+		if err = machine.Run(); err != nil {
+			t.Errorf("Case %v, unexpected error: %v", i, err)
+		}
+
+		if received := machine.GetTapeState()[test.cell].ToUint8(); received != 1 {
+			t.Errorf("Case %v, cell %v, value mismatch. Expected \"1\", received \"%v\"", i, test.cell, received)
+		}
+	}
+}
+
+func TestCellSizes(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected uint64
+	}{
+		{source: `+++++[-]`, expected: 0},
+		{source: `-[+]+`, expected: 1},
+		{source: `+++++[---]`, expected: 0},
+		{source: `-[-]`, expected: 0},
+		{source: `++++[--]`, expected: 0},
+		{source: `+++[->+<]>[-<+>]`, expected: 3},
+		{source: `-[->+<]>[-<+>]<+`, expected: 0},
+		{source: `---[->++<]>[-<+>]<+++++++`, expected: 1},
+		{source: `>>+++++++[-<<+++>>]<<+`, expected: 22},
+	}
+
+	for _, size := range []int{8, 16, 32, 64} {
+		for i, test := range testCases {
+			machine, err := vm.WithCellSize(size)
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if err = machine.LoadFromString(test.source); err != nil {
+				t.Errorf("Size %v, case %v, unexpected error: %v", size, i, err)
+			}
+
+			if err = machine.Run(); err != nil {
+				t.Errorf("Size %v, case %v, unexpected error: %v", size, i, err)
+			}
+
+			if received := machine.GetTapeState()[0].ToUint64(); received != test.expected {
+				t.Errorf("Size %v, case %v, value mismatch. Expected \"%v\", received \"%v\"", size, i, test.expected, received)
+			}
+		}
+	}
+}
+
+func TestOptimizeMatchesPlain(t *testing.T) {
+	testCases := []string{
+		"++++++++[>++++[>++>+++>+++>+<<<<-]>+>+>->>+[<]<-]>>.>---.+++++++..+++.>>.<-.<.+++.------.--------.>>+.>++.",
+		"+[-->-[>>+>-----<<]<--<---]>-.>>>+.>>..+++[.>]<<<<.+++.------.<<-.>>>>+.",
+		"-[-]++++[--]>-[+]>+++++[---]<<[-]+++++[-.]",
+		">>>>++++++++++[->++++++++++[-<<<+<+<+>>>>>]<]<<+++++<++<--[.>]",
+		"+>+>+>+>>+<<<<<[>]+>>>>>>+>+>>+<<[>>]>+[<<<]+",
+		"<+<+<+<<<[<]>+>>[>>>]+",
+		">+>++>+++<<<<-[>+<-]>>>[-]<<<<+++.>>>>>-<<<<<",
+		"++++[->+++<]>[->++>+++>-<<<]>>>>[-<<<<+>>>>]<<[-<+>]",
+	}
+
+	for i, source := range testCases {
+		outputs := make([]string, optimizer.MaxLevel+1)
+		tapes := make([][]vm.Cell, optimizer.MaxLevel+1)
+
+		for j := range outputs {
+			machine, err := vm.New()
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			pipeline, _ := optimizer.New(j)
+			machine.SetPipeline(pipeline)
+
+			if err = machine.LoadFromString(source); err != nil {
+				t.Errorf("Case %v, unexpected error: %v", i, err)
+			}
+
+			writer := strings.Builder{}
+			machine.SetIO(strings.NewReader(""), &writer)
+
+			if err = machine.Run(); err != nil {
+				t.Errorf("Case %v, unexpected error: %v", i, err)
+			}
+
+			outputs[j] = writer.String()
+			tapes[j] = machine.GetTapeState()
+		}
+
+		for level := 1; level < len(outputs); level++ {
+			if outputs[0] != outputs[level] {
+				t.Errorf("Case %v, level %v, output mismatch. Plain \"%v\", optimized \"%v\"", i, level, outputs[0], outputs[level])
+			}
+
+			for j := range tapes[0] {
+				if tapes[0][j].ToUint64() != tapes[level][j].ToUint64() {
+					t.Errorf("Case %v, level %v, cell %v, value mismatch. Plain \"%v\", optimized \"%v\"", i, level, j, tapes[0][j], tapes[level][j])
+				}
+			}
+		}
+	}
+}
+
+func TestUnmatchedBrackets(t *testing.T) {
+	testCases := []struct {
+		source string
+		count  int
+	}{
+		{source: `+[`, count: 1},
+		{source: `-]`, count: 1},
+		{source: `][[]`, count: 2},
+	}
+
+	for i, test := range testCases {
+		_, err := vm.LoadFromString(test.source)
+
+		if list, ok := err.(parser.ErrorList); !ok {
+			t.Errorf("Case %v, wrong error type. Expected \"ErrorList\", received \"%T\"", i, err)
+		} else if len(list) != test.count {
+			t.Errorf("Case %v, expected %v errors, received %v", i, test.count, len(list))
+		}
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	testCases := []struct {
+		level int
+		spans []string
+	}{
+		{level: 0, spans: []string{"1:1-1:3", "2:1-2:2", "2:2-2:3", "2:3-2:4", "3:1-3:2", "3:2-3:3"}},
+		{level: optimizer.MaxLevel, spans: []string{"1:1-1:3", "2:1-2:4", "3:1-3:2", "3:2-3:3"}},
+	}
+
+	for i, test := range testCases {
+		machine, err := vm.New()
+
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		pipeline, _ := optimizer.New(test.level)
+		machine.SetPipeline(pipeline)
+
+		if err = machine.LoadFromString("++ start\n[-]\n.. print"); err != nil {
+			t.Fatalf("Case %v, unexpected error: %v", i, err)
+		}
+
+		spans := machine.SourceMap()
+		if len(spans) != len(test.spans) {
+			t.Errorf("Case %v, mismatched size. Received \"%v\", expected \"%v\"", i, spans, test.spans)
+			continue
+		}
+
+		for j, expected := range test.spans {
+			if spans[j].String() != expected {
+				t.Errorf("Case %v, instruction %v, received \"%v\", expected \"%v\"", i, j, spans[j], expected)
+			}
+
+			if span, ok := machine.SourceSpan(j); !ok || span != spans[j] {
+				t.Errorf("Case %v, instruction %v, SourceSpan returned \"%v\", \"%v\"", i, j, span, ok)
+			}
+		}
+
+		if _, ok := machine.SourceSpan(len(spans)); ok {
+			t.Errorf("Case %v, SourceSpan should fail past the last instruction", i)
+		}
+	}
+}